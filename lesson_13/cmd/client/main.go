@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"strings"
 )
 
 func main() {
@@ -57,6 +58,28 @@ func main() {
 			continue
 		}
 		printResponse(resp)
+
+		// A Watch turns the connection into a stream of events.
+		if req, err := protocol.DecodeRequest(line); err == nil && strings.TrimSpace(req.Cmd) == protocol.CmdWatch && resp.OK {
+			printEvents(r)
+			break
+		}
+	}
+}
+
+func printEvents(r *bufio.Reader) {
+	for {
+		respLine, err := protocol.ReadMessage(r)
+		if err != nil {
+			log.Println("read:", err)
+			return
+		}
+		resp, err := protocol.DecodeResponse(trimNewline(respLine))
+		if err != nil {
+			fmt.Println(string(respLine))
+			continue
+		}
+		printResponse(resp)
 	}
 }
 
@@ -69,7 +92,9 @@ func trimNewline(b []byte) []byte {
 
 func printResponse(resp *protocol.Response) {
 	if resp.OK {
-		if len(resp.Names) > 0 {
+		if resp.Event != nil {
+			fmt.Printf("event: %s %s old=%+v new=%+v\n", resp.Event.Type, resp.Event.Key, resp.Event.Old, resp.Event.New)
+		} else if len(resp.Names) > 0 {
 			fmt.Println("names:", resp.Names)
 		} else if resp.Doc != nil {
 			fmt.Printf("doc: %+v\n", resp.Doc)
@@ -78,9 +103,30 @@ func printResponse(resp *protocol.Response) {
 			for i, d := range resp.Docs {
 				fmt.Printf("  [%d] %+v\n", i, d)
 			}
+			if resp.NextCursor != "" {
+				fmt.Println("next_cursor:", resp.NextCursor)
+			}
+		} else if len(resp.Results) > 0 {
+			fmt.Printf("results: %d item(s)\n", len(resp.Results))
+			for i, r := range resp.Results {
+				fmt.Printf("  [%d] %+v\n", i, r)
+			}
+		} else if resp.Count != nil {
+			fmt.Println("count:", *resp.Count)
+		} else if resp.Exists != nil {
+			fmt.Println("exists:", *resp.Exists)
+		} else if resp.Key != "" {
+			fmt.Printf("key: %s version: %d\n", resp.Key, resp.Version)
+		} else if resp.Version != 0 {
+			fmt.Println("version:", resp.Version)
 		} else {
 			fmt.Println("ok")
 		}
+	} else if resp.Code != "" {
+		fmt.Printf("error [%s]: %s\n", resp.Code, resp.Err)
+		for _, fe := range resp.Errors {
+			fmt.Printf("  %s: %s\n", fe.Path, fe.Message)
+		}
 	} else {
 		fmt.Println("error:", resp.Err)
 	}
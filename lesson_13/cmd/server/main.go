@@ -2,37 +2,94 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"io"
 	"lesson_13/internal/conv"
 	"lesson_13/internal/document_store"
 	"lesson_13/internal/protocol"
 	"log"
 	"net"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
-	store := document_store.NewStore()
+	dataDir := flag.String("data", "data", "directory for store snapshots and the write-ahead log")
+	snapshotInterval := flag.Duration("snapshot-interval", time.Minute, "how often to snapshot the store (0 disables)")
+	snapshotRetain := flag.Int("snapshot-retain", 3, "number of snapshots to keep")
+	reapInterval := flag.Duration("reap-interval", time.Second, "how often to delete expired documents (0 disables)")
+	flag.Parse()
+
+	store, err := document_store.NewStoreFromWAL(*dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	log.Printf("Store opened from %s", *dataDir)
+
+	snapshots, err := document_store.NewSnapshotManager(store, document_store.SnapshotConfig{
+		Interval: *snapshotInterval,
+		Retain:   *snapshotRetain,
+		OnError: func(err error) {
+			log.Println("snapshot:", err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	snapshots.Start()
+
+	store.StartReaper(*reapInterval, func(err error) {
+		log.Println("reaper:", err)
+	})
+
 	listener, err := net.Listen("tcp", ":8080")
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer listener.Close()
 	log.Println("Server listening on :8080")
 
+	// On SIGINT or SIGTERM the listener is closed, which ends the accept loop.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				break
+			}
 			log.Println("accept:", err)
 			continue
 		}
 		go handleConn(conn, store)
 	}
+
+	log.Println("Shutting down")
+	store.StopReaper()
+	snapshots.Stop()
+	if *snapshotInterval > 0 {
+		if _, err := snapshots.Snapshot(); err != nil {
+			log.Println("snapshot:", err)
+		}
+	}
 }
 
 func handleConn(conn net.Conn, store *document_store.Store) {
 	defer conn.Close()
 	r := bufio.NewReader(conn)
 	w := bufio.NewWriter(conn)
+	sess := &session{}
+	defer sess.close()
 
 	for {
 		req, err := protocol.ReadRequest(r)
@@ -40,7 +97,15 @@ func handleConn(conn net.Conn, store *document_store.Store) {
 			return
 		}
 
-		resp := handleRequest(store, req)
+		var resp *protocol.Response
+		if err := conv.WireKeys(req); err != nil {
+			resp = errorResponse(err)
+		} else if strings.TrimSpace(req.Cmd) == protocol.CmdWatch {
+			handleWatch(store, req, r, w)
+			return
+		} else if resp = sess.handleTx(store, req); resp == nil {
+			resp = handleRequest(store, req)
+		}
 		if err := protocol.WriteResponse(w, resp); err != nil {
 			return
 		}
@@ -50,6 +115,140 @@ func handleConn(conn net.Conn, store *document_store.Store) {
 	}
 }
 
+// session holds the state of one connection: the transaction begun on it,
+// which buffers the writes sent until Commit or Rollback.
+type session struct {
+	tx *document_store.Tx
+}
+
+func (s *session) close() {
+	if s.tx != nil {
+		s.tx.Rollback()
+	}
+}
+
+// handleTx handles the transaction commands and the writes sent inside a
+// transaction. It returns nil for other requests.
+func (s *session) handleTx(store *document_store.Store, req *protocol.Request) *protocol.Response {
+	cmd := strings.TrimSpace(req.Cmd)
+	switch cmd {
+	case protocol.CmdBegin:
+		if s.tx != nil {
+			return &protocol.Response{OK: false, Err: "transaction already begun", Code: protocol.CodeBadRequest}
+		}
+		s.tx = store.Begin()
+		return &protocol.Response{OK: true}
+	case protocol.CmdCommit, protocol.CmdRollback:
+		if s.tx == nil {
+			return &protocol.Response{OK: false, Err: "no transaction", Code: protocol.CodeBadRequest}
+		}
+		tx := s.tx
+		s.tx = nil
+		var err error
+		if cmd == protocol.CmdCommit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			return errorResponse(err)
+		}
+		return &protocol.Response{OK: true}
+	}
+	if s.tx == nil {
+		return nil
+	}
+
+	var key string
+	var err error
+	switch cmd {
+	case protocol.CmdPut:
+		doc := conv.WireToDocument(req.Doc)
+		if doc == nil {
+			return &protocol.Response{OK: false, Err: "doc required", Code: protocol.CodeBadRequest}
+		}
+		if req.Version != nil {
+			key, err = s.tx.PutIfVersion(req.Collection, doc, *req.Version)
+		} else {
+			key, err = s.tx.Put(req.Collection, doc)
+		}
+	case protocol.CmdDelete:
+		if req.Version != nil {
+			err = s.tx.DeleteIfVersion(req.Collection, req.Key, *req.Version)
+		} else {
+			err = s.tx.Delete(req.Collection, req.Key)
+		}
+	case protocol.CmdUpdate:
+		if req.Patch == nil {
+			return &protocol.Response{OK: false, Err: "patch required", Code: protocol.CodeBadRequest}
+		}
+		err = s.tx.Update(req.Collection, req.Key, conv.WirePatch(req.Patch))
+	case protocol.CmdBatch:
+		results := make([]protocol.ResultWire, len(req.Ops))
+		for i, op := range req.Ops {
+			var opKey string
+			switch op.Op {
+			case protocol.BatchOpPut:
+				opKey, err = s.tx.Put(req.Collection, conv.WireToDocument(op.Doc))
+			case protocol.BatchOpDelete:
+				err = s.tx.Delete(req.Collection, op.Key)
+			default:
+				err = errUnknownBatchOp
+			}
+			results[i] = batchResult(err, opKey, nil)
+		}
+		return &protocol.Response{OK: true, Results: results}
+	default:
+		return nil
+	}
+	if err != nil {
+		return errorResponse(err)
+	}
+	return &protocol.Response{OK: true, Key: key}
+}
+
+// handleWatch streams the change events of a collection as responses until
+// the client disconnects or the subscription ends. The connection serves no
+// other requests afterwards.
+func handleWatch(store *document_store.Store, req *protocol.Request, r *bufio.Reader, w *bufio.Writer) {
+	write := func(resp *protocol.Response) bool {
+		return protocol.WriteResponse(w, resp) == nil && w.Flush() == nil
+	}
+	col, err := store.GetCollection(req.Collection)
+	if err != nil {
+		write(errorResponse(err))
+		return
+	}
+	options := document_store.WatchOptions{Key: req.Key}
+	if req.Filter != nil {
+		filter := conv.WireToFilter(req.Filter)
+		options.Filter = &filter
+	}
+	sub, err := col.Watch(options)
+	if err != nil {
+		write(errorResponse(err))
+		return
+	}
+	defer sub.Close()
+	if !write(&protocol.Response{OK: true}) {
+		return
+	}
+
+	// Nothing more is read; reading only notices that the client has gone.
+	go func() {
+		io.Copy(io.Discard, r)
+		sub.Close()
+	}()
+	for event := range sub.Events() {
+		if !write(&protocol.Response{OK: true, Event: conv.EventToWire(event)}) {
+			return
+		}
+	}
+	if err := sub.Err(); err != nil {
+		write(errorResponse(err))
+	}
+}
+
 func handleRequest(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	switch strings.TrimSpace(req.Cmd) {
 	case protocol.CmdCreateCollection:
@@ -74,19 +273,50 @@ func handleRequest(store *document_store.Store, req *protocol.Request) *protocol
 		return handleDeleteIndex(store, req)
 	case protocol.CmdQuery:
 		return handleQuery(store, req)
+	case protocol.CmdFind:
+		return handleFind(store, req)
+	case protocol.CmdAggregate:
+		return handleAggregate(store, req)
+	case protocol.CmdCount:
+		return handleCount(store, req)
+	case protocol.CmdExists:
+		return handleExists(store, req)
+	case protocol.CmdUpdate:
+		return handleUpdate(store, req)
+	case protocol.CmdBatch:
+		return handleBatch(store, req)
 	default:
-		return &protocol.Response{OK: false, Err: "unknown command: " + req.Cmd}
+		return &protocol.Response{OK: false, Err: "unknown command: " + req.Cmd, Code: protocol.CodeBadRequest}
 	}
 }
 
 func handleCreateCollection(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	if req.Config == nil {
-		return &protocol.Response{OK: false, Err: "config required"}
+		return &protocol.Response{OK: false, Err: "config required", Code: protocol.CodeBadRequest}
+	}
+	config := &document_store.CollectionConfig{
+		PrimaryKey:   req.Config.PrimaryKey,
+		NumericKey:   req.Config.NumericKey,
+		KeyFields:    req.Config.KeyFields,
+		TTLField:     req.Config.TTLField,
+		MaxDocuments: req.Config.MaxDocuments,
+		MaxBytes:     req.Config.MaxBytes,
+		GenerateKeys: req.Config.GenerateKeys,
 	}
-	config := &document_store.CollectionConfig{PrimaryKey: req.Config.PrimaryKey}
-	_, err := store.CreateCollection(req.Name, config)
+	if req.Config.TTL != "" {
+		ttl, err := time.ParseDuration(req.Config.TTL)
+		if err != nil || ttl < 0 {
+			return &protocol.Response{OK: false, Err: "invalid ttl: " + req.Config.TTL, Code: protocol.CodeBadRequest}
+		}
+		config.TTL = ttl
+	}
+	schema, err := conv.WireSchema(req.Config.Schema)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
+	}
+	config.Schema = schema
+	if _, err := store.CreateCollection(req.Name, config); err != nil {
+		return errorResponse(err)
 	}
 	return &protocol.Response{OK: true}
 }
@@ -94,7 +324,7 @@ func handleCreateCollection(store *document_store.Store, req *protocol.Request)
 func handleGetCollection(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	_, err := store.GetCollection(req.Name)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
 	return &protocol.Response{OK: true}
 }
@@ -102,7 +332,7 @@ func handleGetCollection(store *document_store.Store, req *protocol.Request) *pr
 func handleDeleteCollection(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	err := store.DeleteCollection(req.Name)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
 	return &protocol.Response{OK: true}
 }
@@ -115,39 +345,120 @@ func handleListCollections(store *document_store.Store) *protocol.Response {
 func handlePut(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	col, err := store.GetCollection(req.Collection)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
 	doc := conv.WireToDocument(req.Doc)
 	if doc == nil {
-		return &protocol.Response{OK: false, Err: "doc required"}
+		return &protocol.Response{OK: false, Err: "doc required", Code: protocol.CodeBadRequest}
+	}
+	var key string
+	if req.Version != nil {
+		key, err = col.PutIfVersion(doc, *req.Version)
+	} else {
+		key, err = col.Put(doc)
 	}
-	err = col.Put(doc)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
-	return &protocol.Response{OK: true}
+	return &protocol.Response{OK: true, Key: key, Version: doc.Version}
 }
 
 func handleGet(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	col, err := store.GetCollection(req.Collection)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
+	}
+	doc, err := col.GetWithProjection(req.Key, conv.WireProjection(req.Projection))
+	if err != nil {
+		return errorResponse(err)
+	}
+	return &protocol.Response{OK: true, Doc: conv.DocumentToWire(doc)}
+}
+
+func handleUpdate(store *document_store.Store, req *protocol.Request) *protocol.Response {
+	col, err := store.GetCollection(req.Collection)
+	if err != nil {
+		return errorResponse(err)
 	}
-	doc, err := col.Get(req.Key)
+	if req.Patch == nil {
+		return &protocol.Response{OK: false, Err: "patch required", Code: protocol.CodeBadRequest}
+	}
+	doc, err := col.Update(req.Key, conv.WirePatch(req.Patch))
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
 	return &protocol.Response{OK: true, Doc: conv.DocumentToWire(doc)}
 }
 
+var errUnknownBatchOp = errors.New("unknown batch op")
+
+// handleBatch applies each run of consecutive puts or deletes with a single
+// PutMany or DeleteMany call.
+func handleBatch(store *document_store.Store, req *protocol.Request) *protocol.Response {
+	col, err := store.GetCollection(req.Collection)
+	if err != nil {
+		return errorResponse(err)
+	}
+	results := make([]protocol.ResultWire, len(req.Ops))
+	for start := 0; start < len(req.Ops); {
+		end := start + 1
+		for end < len(req.Ops) && req.Ops[end].Op == req.Ops[start].Op {
+			end++
+		}
+		ops := req.Ops[start:end]
+
+		switch ops[0].Op {
+		case protocol.BatchOpPut:
+			docs := make([]*document_store.Document, len(ops))
+			for i, op := range ops {
+				docs[i] = conv.WireToDocument(op.Doc)
+			}
+			keys, errs := col.PutMany(docs)
+			for i, err := range errs {
+				results[start+i] = batchResult(err, keys[i], docs[i])
+			}
+		case protocol.BatchOpDelete:
+			keys := make([]string, len(ops))
+			for i, op := range ops {
+				keys[i] = op.Key
+			}
+			for i, err := range col.DeleteMany(keys) {
+				results[start+i] = batchResult(err, "", nil)
+			}
+		default:
+			for i := range ops {
+				results[start+i] = batchResult(errUnknownBatchOp, "", nil)
+			}
+		}
+		start = end
+	}
+	return &protocol.Response{OK: true, Results: results}
+}
+
+// batchResult reports the outcome of one batch op. key is the key a put was
+// stored under and doc the document it stored, if any.
+func batchResult(err error, key string, doc *document_store.Document) protocol.ResultWire {
+	if err != nil {
+		return protocol.ResultWire{OK: false, Err: err.Error(), Code: errorCode(err), Errors: conv.FieldErrorsToWire(err)}
+	}
+	if doc != nil {
+		return protocol.ResultWire{OK: true, Key: key, Version: doc.Version}
+	}
+	return protocol.ResultWire{OK: true, Key: key}
+}
+
 func handleDelete(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	col, err := store.GetCollection(req.Collection)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
+	}
+	if req.Version != nil {
+		err = col.DeleteIfVersion(req.Key, *req.Version)
+	} else {
+		err = col.Delete(req.Key)
 	}
-	err = col.Delete(req.Key)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
 	return &protocol.Response{OK: true}
 }
@@ -155,24 +466,27 @@ func handleDelete(store *document_store.Store, req *protocol.Request) *protocol.
 func handleList(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	col, err := store.GetCollection(req.Collection)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
-	docs := col.List()
-	wires := make([]protocol.DocWire, 0, len(docs))
-	for i := range docs {
-		wires = append(wires, *conv.DocumentToWire(&docs[i]))
+	page, err := col.ListPage(document_store.ListParams{
+		PageParams: conv.WirePageParams(req),
+		Sort:       conv.WireSort(req.Sort),
+		Projection: conv.WireProjection(req.Projection),
+	})
+	if err != nil {
+		return errorResponse(err)
 	}
-	return &protocol.Response{OK: true, Docs: wires}
+	return pageResponse(page)
 }
 
 func handleCreateIndex(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	col, err := store.GetCollection(req.Collection)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
-	err = col.CreateIndex(req.FieldName)
+	err = col.CreateIndex(req.FieldName, conv.WireIndexOptions(req.Index))
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
 	return &protocol.Response{OK: true}
 }
@@ -180,11 +494,11 @@ func handleCreateIndex(store *document_store.Store, req *protocol.Request) *prot
 func handleDeleteIndex(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	col, err := store.GetCollection(req.Collection)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
 	err = col.DeleteIndex(req.FieldName)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
 	return &protocol.Response{OK: true}
 }
@@ -192,12 +506,34 @@ func handleDeleteIndex(store *document_store.Store, req *protocol.Request) *prot
 func handleQuery(store *document_store.Store, req *protocol.Request) *protocol.Response {
 	col, err := store.GetCollection(req.Collection)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
 	}
 	params := conv.WireQueryParams(req.Params)
-	docs, err := col.Query(req.FieldName, params)
+	params.PageParams = conv.WirePageParams(req)
+	params.Projection = conv.WireProjection(req.Projection)
+	page, err := col.QueryPage(req.FieldName, params)
+	if err != nil {
+		return errorResponse(err)
+	}
+	return pageResponse(page)
+}
+
+func pageResponse(page *document_store.Page) *protocol.Response {
+	wires := make([]protocol.DocWire, 0, len(page.Docs))
+	for i := range page.Docs {
+		wires = append(wires, *conv.DocumentToWire(&page.Docs[i]))
+	}
+	return &protocol.Response{OK: true, Docs: wires, NextCursor: page.NextCursor}
+}
+
+func handleFind(store *document_store.Store, req *protocol.Request) *protocol.Response {
+	col, err := store.GetCollection(req.Collection)
 	if err != nil {
-		return &protocol.Response{OK: false, Err: err.Error()}
+		return errorResponse(err)
+	}
+	docs, err := col.Find(conv.WireToFilter(req.Filter))
+	if err != nil {
+		return errorResponse(err)
 	}
 	wires := make([]protocol.DocWire, 0, len(docs))
 	for i := range docs {
@@ -205,3 +541,79 @@ func handleQuery(store *document_store.Store, req *protocol.Request) *protocol.R
 	}
 	return &protocol.Response{OK: true, Docs: wires}
 }
+
+func handleAggregate(store *document_store.Store, req *protocol.Request) *protocol.Response {
+	col, err := store.GetCollection(req.Collection)
+	if err != nil {
+		return errorResponse(err)
+	}
+	docs, err := col.Aggregate(conv.WireAggregateParams(req))
+	if err != nil {
+		return errorResponse(err)
+	}
+	wires := make([]protocol.DocWire, 0, len(docs))
+	for i := range docs {
+		wires = append(wires, *conv.DocumentToWire(&docs[i]))
+	}
+	return &protocol.Response{OK: true, Docs: wires}
+}
+
+func handleCount(store *document_store.Store, req *protocol.Request) *protocol.Response {
+	col, err := store.GetCollection(req.Collection)
+	if err != nil {
+		return errorResponse(err)
+	}
+	count, err := col.Count(conv.WireToFilter(req.Filter))
+	if err != nil {
+		return errorResponse(err)
+	}
+	return &protocol.Response{OK: true, Count: &count}
+}
+
+func handleExists(store *document_store.Store, req *protocol.Request) *protocol.Response {
+	col, err := store.GetCollection(req.Collection)
+	if err != nil {
+		return errorResponse(err)
+	}
+	exists := col.Exists(req.Key)
+	return &protocol.Response{OK: true, Exists: &exists}
+}
+
+func errorResponse(err error) *protocol.Response {
+	return &protocol.Response{OK: false, Err: err.Error(), Code: errorCode(err), Errors: conv.FieldErrorsToWire(err)}
+}
+
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, document_store.ErrVersionConflict):
+		return protocol.CodeVersionConflict
+	case errors.Is(err, document_store.ErrUniqueConstraintViolation):
+		return protocol.CodeUniqueViolation
+	case errors.Is(err, document_store.ErrSubscriberTooSlow):
+		return protocol.CodeSubscriberTooSlow
+	case errors.Is(err, document_store.ErrSchemaViolation):
+		return protocol.CodeSchemaViolation
+	case errors.Is(err, document_store.ErrDocumentNotFound),
+		errors.Is(err, document_store.ErrCollectionNotFound),
+		errors.Is(err, document_store.ErrIndexNotFound):
+		return protocol.CodeNotFound
+	case errors.Is(err, document_store.ErrCollectionAlreadyExists),
+		errors.Is(err, document_store.ErrIndexAlreadyExists):
+		return protocol.CodeAlreadyExists
+	case errors.Is(err, document_store.ErrUnsupportedDocumentField),
+		errors.Is(err, document_store.ErrInvalidQuery),
+		errors.Is(err, document_store.ErrInvalidFilter),
+		errors.Is(err, document_store.ErrInvalidCursor),
+		errors.Is(err, document_store.ErrInvalidProjection),
+		errors.Is(err, document_store.ErrInvalidAggregation),
+		errors.Is(err, document_store.ErrInvalidPatch),
+		errors.Is(err, document_store.ErrTxDone),
+		errors.Is(err, document_store.ErrDocumentTooLarge),
+		errors.Is(err, document_store.ErrInvalidSchema),
+		errors.Is(err, document_store.ErrInvalidKey),
+		errors.Is(err, document_store.ErrInvalidConfig),
+		errors.Is(err, errUnknownBatchOp):
+		return protocol.CodeBadRequest
+	}
+	return protocol.CodeInternal
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"lesson_13/internal/document_store"
+	"lesson_13/internal/protocol"
+	"net"
+	"testing"
+)
+
+// client talks to handleConn over an in-memory connection.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	done chan struct{}
+}
+
+func newClient(t *testing.T, store *document_store.Store) *client {
+	t.Helper()
+	conn, server := net.Pipe()
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn), done: make(chan struct{})}
+	go func() {
+		handleConn(server, store)
+		close(c.done)
+	}()
+	t.Cleanup(c.close)
+	return c
+}
+
+// close ends the connection and waits for handleConn to return.
+func (c *client) close() {
+	c.conn.Close()
+	<-c.done
+}
+
+func (c *client) do(req *protocol.Request) *protocol.Response {
+	c.t.Helper()
+	data, err := protocol.EncodeRequest(req)
+	if err != nil {
+		c.t.Fatalf("unexpected error encoding request: %v", err)
+	}
+	if err := protocol.WriteMessage(c.w, data); err != nil {
+		c.t.Fatalf("unexpected error writing request: %v", err)
+	}
+	if err := c.w.Flush(); err != nil {
+		c.t.Fatalf("unexpected error writing request: %v", err)
+	}
+	line, err := protocol.ReadMessage(c.r)
+	if err != nil {
+		c.t.Fatalf("unexpected error reading response: %v", err)
+	}
+	resp, err := protocol.DecodeResponse(line)
+	if err != nil {
+		c.t.Fatalf("unexpected error decoding response: %v", err)
+	}
+	return resp
+}
+
+func (c *client) mustDo(req *protocol.Request) *protocol.Response {
+	c.t.Helper()
+	resp := c.do(req)
+	if !resp.OK {
+		c.t.Fatalf("unexpected error on %s: %s", req.Cmd, resp.Err)
+	}
+	return resp
+}
+
+func userWire(id, name string) *protocol.DocWire {
+	return &protocol.DocWire{Fields: map[string]protocol.DocFieldWire{
+		"id":   {Type: "string", Value: id},
+		"name": {Type: "string", Value: name},
+	}}
+}
+
+func newUsersStore(t *testing.T) *document_store.Store {
+	t.Helper()
+	store := document_store.NewStore()
+	if _, err := store.CreateCollection("users", &document_store.CollectionConfig{PrimaryKey: "id"}); err != nil {
+		t.Fatalf("unexpected error creating collection: %v", err)
+	}
+	return store
+}
+
+func assertExists(t *testing.T, c *client, collection, key string, want bool) {
+	t.Helper()
+	resp := c.mustDo(&protocol.Request{Cmd: protocol.CmdExists, Collection: collection, Key: key})
+	if *resp.Exists != want {
+		t.Fatalf("expected Exists(%q) to be %v, got %v", key, want, *resp.Exists)
+	}
+}
+
+func TestTx_CommitAndRollback(t *testing.T) {
+	store := newUsersStore(t)
+	writer, reader := newClient(t, store), newClient(t, store)
+
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdBegin})
+	resp := writer.mustDo(&protocol.Request{Cmd: protocol.CmdPut, Collection: "users", Doc: userWire("1", "Alice")})
+	if resp.Key != "1" {
+		t.Fatalf("expected key 1, got %q", resp.Key)
+	}
+	assertExists(t, reader, "users", "1", false)
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdCommit})
+	assertExists(t, reader, "users", "1", true)
+
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdBegin})
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdDelete, Collection: "users", Key: "1"})
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdPut, Collection: "users", Doc: userWire("2", "Bob")})
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdRollback})
+	assertExists(t, reader, "users", "1", true)
+	assertExists(t, reader, "users", "2", false)
+
+	// Without a transaction writes apply at once.
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdPut, Collection: "users", Doc: userWire("3", "Carol")})
+	assertExists(t, reader, "users", "3", true)
+	if resp := writer.do(&protocol.Request{Cmd: protocol.CmdCommit}); resp.OK || resp.Code != protocol.CodeBadRequest {
+		t.Fatalf("expected a bad request for Commit without a transaction, got %+v", resp)
+	}
+}
+
+func TestTx_AbortedConnectionRollsBack(t *testing.T) {
+	store := newUsersStore(t)
+	writer := newClient(t, store)
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdBegin})
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdPut, Collection: "users", Doc: userWire("1", "Alice")})
+	writer.close()
+
+	assertExists(t, newClient(t, store), "users", "1", false)
+
+	sess := &session{}
+	sess.handleTx(store, &protocol.Request{Cmd: protocol.CmdBegin})
+	sess.handleTx(store, &protocol.Request{Cmd: protocol.CmdPut, Collection: "users", Doc: userWire("2", "Bob")})
+	tx := sess.tx
+	sess.close()
+	if err := tx.Commit(); err != document_store.ErrTxDone {
+		t.Fatalf("expected the transaction to be rolled back, got %v", err)
+	}
+}
+
+func TestBatch_MixedOps(t *testing.T) {
+	store := newUsersStore(t)
+	c := newClient(t, store)
+	c.mustDo(&protocol.Request{Cmd: protocol.CmdPut, Collection: "users", Doc: userWire("1", "Alice")})
+
+	resp := c.mustDo(&protocol.Request{Cmd: protocol.CmdBatch, Collection: "users", Ops: []protocol.BatchOpWire{
+		{Op: protocol.BatchOpPut, Doc: userWire("2", "Bob")},
+		{Op: protocol.BatchOpPut, Doc: userWire("3", "Carol")},
+		{Op: protocol.BatchOpDelete, Key: "1"},
+		{Op: protocol.BatchOpDelete, Key: "missing"},
+		{Op: protocol.BatchOpPut, Doc: userWire("4", "Dan")},
+		{Op: "upsert", Key: "5"},
+	}})
+	want := []protocol.ResultWire{
+		{OK: true, Key: "2", Version: 2},
+		{OK: true, Key: "3", Version: 3},
+		{OK: true},
+		{Code: protocol.CodeNotFound},
+		{OK: true, Key: "4", Version: 4},
+		{Code: protocol.CodeBadRequest},
+	}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), resp.Results)
+	}
+	for i, result := range resp.Results {
+		if result.OK != want[i].OK || result.Key != want[i].Key || result.Version != want[i].Version || result.Code != want[i].Code {
+			t.Fatalf("expected result %d to be %+v, got %+v", i, want[i], result)
+		}
+	}
+	for key, want := range map[string]bool{"1": false, "2": true, "3": true, "4": true, "5": false} {
+		assertExists(t, c, "users", key, want)
+	}
+}
+
+func TestBatch_InTransaction(t *testing.T) {
+	store := newUsersStore(t)
+	writer, reader := newClient(t, store), newClient(t, store)
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdPut, Collection: "users", Doc: userWire("1", "Alice")})
+
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdBegin})
+	resp := writer.mustDo(&protocol.Request{Cmd: protocol.CmdBatch, Collection: "users", Ops: []protocol.BatchOpWire{
+		{Op: protocol.BatchOpPut, Doc: userWire("2", "Bob")},
+		{Op: protocol.BatchOpDelete, Key: "1"},
+	}})
+	if len(resp.Results) != 2 || resp.Results[0].Key != "2" || !resp.Results[1].OK {
+		t.Fatalf("expected the put of 2 and the delete of 1 to be buffered, got %+v", resp.Results)
+	}
+	assertExists(t, reader, "users", "1", true)
+	writer.mustDo(&protocol.Request{Cmd: protocol.CmdCommit})
+	assertExists(t, reader, "users", "1", false)
+	assertExists(t, reader, "users", "2", true)
+}
+
+func TestBatch_KeyParts(t *testing.T) {
+	store := document_store.NewStore()
+	store.CreateCollection("orders", &document_store.CollectionConfig{KeyFields: []string{"tenant", "n"}})
+	c := newClient(t, store)
+	order := func(tenant string, n float64) *protocol.DocWire {
+		return &protocol.DocWire{Fields: map[string]protocol.DocFieldWire{
+			"tenant": {Type: "string", Value: tenant},
+			"n":      {Type: "number", Value: n},
+		}}
+	}
+
+	resp := c.mustDo(&protocol.Request{Cmd: protocol.CmdBatch, Collection: "orders", Ops: []protocol.BatchOpWire{
+		{Op: protocol.BatchOpPut, Doc: order("acme", 7)},
+		{Op: protocol.BatchOpPut, Doc: order("acme", 10)},
+	}})
+	if resp.Results[0].Key != `["acme",7]` {
+		t.Fatalf("expected the canonical key [\"acme\",7], got %q", resp.Results[0].Key)
+	}
+
+	resp = c.mustDo(&protocol.Request{Cmd: protocol.CmdBatch, Collection: "orders", Ops: []protocol.BatchOpWire{
+		{Op: protocol.BatchOpDelete, KeyParts: []any{"acme", 7}},
+	}})
+	if !resp.Results[0].OK {
+		t.Fatalf("expected the delete by key parts to succeed, got %+v", resp.Results[0])
+	}
+	if resp := c.do(&protocol.Request{Cmd: protocol.CmdGet, Collection: "orders", KeyParts: []any{"acme", 7}}); resp.Code != protocol.CodeNotFound {
+		t.Fatalf("expected the deleted order to be gone, got %+v", resp)
+	}
+	c.mustDo(&protocol.Request{Cmd: protocol.CmdGet, Collection: "orders", KeyParts: []any{"acme", 10}})
+
+	// Key parts are resolved inside a transaction too.
+	c.mustDo(&protocol.Request{Cmd: protocol.CmdBegin})
+	c.mustDo(&protocol.Request{Cmd: protocol.CmdBatch, Collection: "orders", Ops: []protocol.BatchOpWire{
+		{Op: protocol.BatchOpDelete, KeyParts: []any{"acme", 10}},
+	}})
+	c.mustDo(&protocol.Request{Cmd: protocol.CmdCommit})
+	assertExists(t, c, "orders", `["acme",10]`, false)
+
+	resp = c.do(&protocol.Request{Cmd: protocol.CmdBatch, Collection: "orders", Ops: []protocol.BatchOpWire{
+		{Op: protocol.BatchOpDelete, KeyParts: []any{"acme", true}},
+	}})
+	if resp.OK || resp.Code != protocol.CodeBadRequest {
+		t.Fatalf("expected a bad request for invalid key parts, got %+v", resp)
+	}
+}
@@ -1,6 +1,9 @@
 package conv
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"lesson_13/internal/document_store"
 	"lesson_13/internal/protocol"
 )
@@ -30,16 +33,188 @@ func DocumentToWire(d *document_store.Document) *protocol.DocWire {
 			Value: f.Value,
 		}
 	}
-	return &protocol.DocWire{Fields: fields}
+	return &protocol.DocWire{Fields: fields, Version: d.Version, ExpiresAt: d.ExpiresAt}
 }
 
 func WireQueryParams(p *protocol.QueryParamsWire) document_store.QueryParams {
 	if p == nil {
 		return document_store.QueryParams{}
 	}
+	var prefix []document_store.DocumentField
+	for i := range p.Prefix {
+		prefix = append(prefix, *WireToField(&p.Prefix[i]))
+	}
 	return document_store.QueryParams{
 		Desc:     p.Desc,
-		MinValue: p.MinValue,
-		MaxValue: p.MaxValue,
+		Prefix:   prefix,
+		MinValue: WireToField(p.MinValue),
+		MaxValue: WireToField(p.MaxValue),
+	}
+}
+
+func WirePageParams(req *protocol.Request) document_store.PageParams {
+	return document_store.PageParams{
+		Limit:  req.Limit,
+		Skip:   req.Skip,
+		Cursor: req.Cursor,
+	}
+}
+
+func WireSort(w []protocol.SortWire) []document_store.SortField {
+	var fields []document_store.SortField
+	for _, f := range w {
+		fields = append(fields, document_store.SortField{Field: f.Field, Desc: f.Desc})
+	}
+	return fields
+}
+
+func WireToField(w *protocol.DocFieldWire) *document_store.DocumentField {
+	if w == nil {
+		return nil
+	}
+	return &document_store.DocumentField{
+		Type:  document_store.DocumentFieldType(w.Type),
+		Value: w.Value,
+	}
+}
+
+func WireIndexOptions(w *protocol.IndexWire) document_store.IndexOptions {
+	if w == nil {
+		return document_store.IndexOptions{}
+	}
+	return document_store.IndexOptions{
+		Fields:   w.Fields,
+		Unique:   w.Unique,
+		Multikey: w.Multikey,
+	}
+}
+
+func WireToFilter(w *protocol.FilterWire) document_store.Filter {
+	if w == nil {
+		return document_store.Filter{Op: document_store.FilterOpAnd}
+	}
+	filter := document_store.Filter{
+		Op:    document_store.FilterOp(w.Op),
+		Field: w.Field,
+		Value: WireToField(w.Value),
+	}
+	for i := range w.Values {
+		filter.Values = append(filter.Values, *WireToField(&w.Values[i]))
+	}
+	for i := range w.Filters {
+		filter.Filters = append(filter.Filters, WireToFilter(&w.Filters[i]))
+	}
+	return filter
+}
+
+func WireProjection(w *protocol.ProjectionWire) *document_store.Projection {
+	if w == nil {
+		return nil
+	}
+	return &document_store.Projection{Include: w.Include, Exclude: w.Exclude}
+}
+
+func WireAggregateParams(req *protocol.Request) document_store.AggregateParams {
+	match := WireToFilter(req.Filter)
+	params := document_store.AggregateParams{
+		Match:   &match,
+		GroupBy: req.GroupBy,
+		Sort:    WireSort(req.Sort),
+		Limit:   req.Limit,
+	}
+	for _, acc := range req.Accumulators {
+		params.Accumulators = append(params.Accumulators, document_store.Accumulator{
+			Name:  acc.Name,
+			Op:    document_store.AccumulatorOp(acc.Op),
+			Field: acc.Field,
+		})
+	}
+	return params
+}
+
+func WirePatch(w *protocol.PatchWire) document_store.Patch {
+	if w == nil {
+		return document_store.Patch{}
+	}
+	return document_store.Patch{
+		Set:   wireFields(w.Set),
+		Unset: w.Unset,
+		Inc:   w.Inc,
+		Push:  wireFields(w.Push),
+		Pull:  wireFields(w.Pull),
+	}
+}
+
+func wireFields(w map[string]protocol.DocFieldWire) map[string]document_store.DocumentField {
+	if w == nil {
+		return nil
+	}
+	fields := make(map[string]document_store.DocumentField, len(w))
+	for k, f := range w {
+		fields[k] = *WireToField(&f)
+	}
+	return fields
+}
+
+func EventToWire(e document_store.ChangeEvent) *protocol.EventWire {
+	return &protocol.EventWire{
+		Type:       string(e.Type),
+		Collection: e.Collection,
+		Key:        e.Key,
+		Old:        DocumentToWire(e.OldDoc),
+		New:        DocumentToWire(e.NewDoc),
+	}
+}
+
+// WireSchema decodes a collection schema, which is nil when raw is empty.
+func WireSchema(raw json.RawMessage) (*document_store.Schema, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var schema document_store.Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("%w: %v", document_store.ErrInvalidSchema, err)
+	}
+	return &schema, nil
+}
+
+// FieldErrorsToWire returns the fields that err names: the schema
+// violations it lists or the field it rejects.
+func FieldErrorsToWire(err error) []protocol.FieldErrorWire {
+	var fieldErr *document_store.FieldValueError
+	if errors.As(err, &fieldErr) {
+		return []protocol.FieldErrorWire{{Path: fieldErr.Field, Message: fieldErr.Reason}}
+	}
+	var schemaErr *document_store.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return nil
+	}
+	errs := make([]protocol.FieldErrorWire, len(schemaErr.Errors))
+	for i, fe := range schemaErr.Errors {
+		errs[i] = protocol.FieldErrorWire{Path: fe.Path, Message: fe.Message}
+	}
+	return errs
+}
+
+// WireKeys replaces the key parts of a request and of its batch ops with
+// the canonical keys they name.
+func WireKeys(req *protocol.Request) error {
+	var err error
+	if req.Key, err = wireKey(req.Key, req.KeyParts); err != nil {
+		return err
+	}
+	for i := range req.Ops {
+		op := &req.Ops[i]
+		if op.Key, err = wireKey(op.Key, op.KeyParts); err != nil {
+			return fmt.Errorf("op %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func wireKey(key string, parts []any) (string, error) {
+	if len(parts) == 0 {
+		return key, nil
 	}
+	return document_store.EncodeKey(parts...)
 }
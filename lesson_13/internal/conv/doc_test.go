@@ -0,0 +1,37 @@
+package conv
+
+import (
+	"errors"
+	"lesson_13/internal/document_store"
+	"lesson_13/internal/protocol"
+	"strings"
+	"testing"
+)
+
+func TestWireKeys(t *testing.T) {
+	data := []byte(`{"cmd":"Batch","key_parts":["acme",7],"ops":[
+		{"op":"delete","key":"plain"},
+		{"op":"delete","key_parts":["beta",1.5]},
+		{"op":"delete","key":"ignored","key_parts":["solo"]}]}`)
+	req, err := protocol.DecodeRequest(data)
+	if err != nil {
+		t.Fatalf("unexpected error decoding request: %v", err)
+	}
+	if err := WireKeys(req); err != nil {
+		t.Fatalf("unexpected error on WireKeys: %v", err)
+	}
+	if req.Key != `["acme",7]` {
+		t.Fatalf("expected the request key [\"acme\",7], got %q", req.Key)
+	}
+	want := []string{"plain", `["beta",1.5]`, "solo"}
+	for i, op := range req.Ops {
+		if op.Key != want[i] {
+			t.Fatalf("expected op %d key %q, got %q", i, want[i], op.Key)
+		}
+	}
+
+	req = &protocol.Request{Ops: []protocol.BatchOpWire{{KeyParts: []any{"a"}}, {KeyParts: []any{nil}}}}
+	if err := WireKeys(req); !errors.Is(err, document_store.ErrInvalidKey) || !strings.HasPrefix(err.Error(), "op 1:") {
+		t.Fatalf("expected ErrInvalidKey for op 1, got %v", err)
+	}
+}
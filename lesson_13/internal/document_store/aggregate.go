@@ -0,0 +1,220 @@
+package document_store
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrInvalidAggregation = errors.New("invalid aggregation")
+
+// GroupIDField holds the group value in every aggregation result.
+const GroupIDField = "_id"
+
+type AccumulatorOp string
+
+const (
+	AccumulatorCount AccumulatorOp = "count"
+	AccumulatorSum   AccumulatorOp = "sum"
+	AccumulatorAvg   AccumulatorOp = "avg"
+	AccumulatorMin   AccumulatorOp = "min"
+	AccumulatorMax   AccumulatorOp = "max"
+)
+
+// Accumulator computes the result field Name of every group. All ops but
+// count take the number Field and ignore values of other types; avg, min and
+// max are left out of a group holding no numbers.
+type Accumulator struct {
+	Name  string
+	Op    AccumulatorOp
+	Field string
+}
+
+// AggregateParams describe a pipeline: the documents matching Match (all
+// when nil) are grouped by the value of GroupBy (into a single group when
+// empty), each group is reduced to a result document holding its value in
+// GroupIDField and one field per accumulator, and the results are sorted and
+// limited. Documents whose GroupBy field is missing or is not a string,
+// number or bool form one group without GroupIDField.
+// Results are ordered by group value when Sort is empty, and Sort may refer
+// to GroupIDField and to accumulator names.
+type AggregateParams struct {
+	Match        *Filter
+	GroupBy      string
+	Accumulators []Accumulator
+	Sort         []SortField
+	Limit        int
+}
+
+func (p *AggregateParams) validate() error {
+	if p.Match != nil {
+		if err := p.Match.validate(); err != nil {
+			return err
+		}
+	}
+	if p.Limit < 0 {
+		return ErrInvalidAggregation
+	}
+	for _, field := range p.Sort {
+		if field.Field == "" {
+			return ErrInvalidAggregation
+		}
+	}
+	names := make(map[string]bool)
+	for _, acc := range p.Accumulators {
+		if acc.Name == "" || acc.Name == GroupIDField || names[acc.Name] {
+			return ErrInvalidAggregation
+		}
+		names[acc.Name] = true
+		switch acc.Op {
+		case AccumulatorCount:
+		case AccumulatorSum, AccumulatorAvg, AccumulatorMin, AccumulatorMax:
+			if acc.Field == "" {
+				return ErrInvalidAggregation
+			}
+		default:
+			return ErrInvalidAggregation
+		}
+	}
+	return nil
+}
+
+// group accumulates the documents sharing a group value.
+type group struct {
+	key    string
+	id     *DocumentField
+	count  int
+	sums   []float64
+	counts []int
+	mins   []float64
+	maxes  []float64
+}
+
+func (g *group) add(doc *Document, accumulators []Accumulator) {
+	g.count++
+	for i, acc := range accumulators {
+		if acc.Op == AccumulatorCount {
+			continue
+		}
+		field, exists := lookupField(doc, acc.Field)
+		if !exists || field.Type != DocumentFieldTypeNumber {
+			continue
+		}
+		value, ok := numberValue(field.Value)
+		if !ok {
+			continue
+		}
+		if g.counts[i] == 0 || value < g.mins[i] {
+			g.mins[i] = value
+		}
+		if g.counts[i] == 0 || value > g.maxes[i] {
+			g.maxes[i] = value
+		}
+		g.sums[i] += value
+		g.counts[i]++
+	}
+}
+
+func (g *group) result(accumulators []Accumulator) Document {
+	fields := make(map[string]DocumentField, len(accumulators)+1)
+	if g.id != nil {
+		fields[GroupIDField] = *g.id
+	}
+	for i, acc := range accumulators {
+		var value float64
+		switch acc.Op {
+		case AccumulatorCount:
+			value = float64(g.count)
+		case AccumulatorSum:
+			value = g.sums[i]
+		case AccumulatorAvg:
+			if g.counts[i] == 0 {
+				continue
+			}
+			value = g.sums[i] / float64(g.counts[i])
+		case AccumulatorMin:
+			if g.counts[i] == 0 {
+				continue
+			}
+			value = g.mins[i]
+		case AccumulatorMax:
+			if g.counts[i] == 0 {
+				continue
+			}
+			value = g.maxes[i]
+		}
+		fields[acc.Name] = DocumentField{Type: DocumentFieldTypeNumber, Value: value}
+	}
+	return Document{Fields: fields}
+}
+
+// Aggregate runs the pipeline described by params. The match stage reads only
+// the documents an index selects when one covers the filter.
+func (c *CollectionImpl) Aggregate(params AggregateParams) ([]Document, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	match := params.Match
+	if match == nil {
+		match = &Filter{Op: FilterOpAnd}
+	}
+
+	c.mu.RLock()
+	groups := make(map[string]*group)
+	for _, doc := range c.matching(match) {
+		key, id := groupKey(doc, params.GroupBy)
+		g, exists := groups[key]
+		if !exists {
+			n := len(params.Accumulators)
+			g = &group{
+				key:    key,
+				id:     id,
+				sums:   make([]float64, n),
+				counts: make([]int, n),
+				mins:   make([]float64, n),
+				maxes:  make([]float64, n),
+			}
+			groups[key] = g
+		}
+		g.add(doc, params.Accumulators)
+	}
+	c.mu.RUnlock()
+
+	entries := make([]sortEntry, 0, len(groups))
+	for _, g := range groups {
+		doc := g.result(params.Accumulators)
+		entry := sortEntry{doc: &doc, values: make([]string, len(params.Sort)), id: g.key}
+		for i, sortField := range params.Sort {
+			entry.values[i] = sortValue(&doc, sortField.Field)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return compareSortEntries(entries[i], entries[j], params.Sort) < 0
+	})
+	if params.Limit > 0 && len(entries) > params.Limit {
+		entries = entries[:params.Limit]
+	}
+
+	results := make([]Document, 0, len(entries))
+	for _, entry := range entries {
+		results = append(results, *entry.doc)
+	}
+	return results, nil
+}
+
+// groupKey returns the encoded group value of doc, which orders the groups,
+// and the value itself. The group without a value sorts last.
+func groupKey(doc *Document, groupBy string) (string, *DocumentField) {
+	if groupBy == "" {
+		return "", nil
+	}
+	field, exists := lookupField(doc, groupBy)
+	if !exists {
+		return "\xff", nil
+	}
+	key, ok := encodeIndexValue(field)
+	if !ok {
+		return "\xff", nil
+	}
+	return key, &field
+}
@@ -0,0 +1,135 @@
+package document_store
+
+import (
+	"reflect"
+	"testing"
+)
+
+func aggregate(t *testing.T, col *CollectionImpl, params AggregateParams) []map[string]any {
+	t.Helper()
+	docs, err := col.Aggregate(params)
+	if err != nil {
+		t.Fatalf("unexpected error on Aggregate: %v", err)
+	}
+	results := make([]map[string]any, 0, len(docs))
+	for _, doc := range docs {
+		result := make(map[string]any, len(doc.Fields))
+		for name, field := range doc.Fields {
+			result[name] = field.Value
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func TestAggregate_GroupBy(t *testing.T) {
+	col := newEventsCollection(t)
+
+	got := aggregate(t, col, AggregateParams{
+		GroupBy: "tenant",
+		Accumulators: []Accumulator{
+			{Name: "count", Op: AccumulatorCount},
+			{Name: "total", Op: AccumulatorSum, Field: "created_at"},
+			{Name: "avg", Op: AccumulatorAvg, Field: "created_at"},
+			{Name: "first", Op: AccumulatorMin, Field: "created_at"},
+			{Name: "last", Op: AccumulatorMax, Field: "created_at"},
+		},
+	})
+	want := []map[string]any{
+		{"_id": "ac", "count": 1.0, "total": 150.0, "avg": 150.0, "first": 150.0, "last": 150.0},
+		{"_id": "acme", "count": 4.0, "total": 600.0, "avg": 200.0, "first": 100.0, "last": 300.0},
+		{"_id": "globex", "count": 1.0, "total": 100.0, "avg": 100.0, "first": 100.0, "last": 100.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAggregate_MatchSortAndLimit(t *testing.T) {
+	col := newEventsCollection(t)
+	col.CreateIndex("created_at")
+
+	got := aggregate(t, col, AggregateParams{
+		Match:        &Filter{Op: FilterOpGte, Field: "created_at", Value: number(150)},
+		GroupBy:      "tenant",
+		Accumulators: []Accumulator{{Name: "total", Op: AccumulatorSum, Field: "created_at"}},
+		Sort:         []SortField{{Field: "total", Desc: true}},
+	})
+	want := []map[string]any{
+		{"_id": "acme", "total": 500.0},
+		{"_id": "ac", "total": 150.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	got = aggregate(t, col, AggregateParams{
+		GroupBy:      "tenant",
+		Accumulators: []Accumulator{{Name: "count", Op: AccumulatorCount}},
+		Sort:         []SortField{{Field: "count", Desc: true}},
+		Limit:        2,
+	})
+	want = []map[string]any{
+		{"_id": "acme", "count": 4.0},
+		{"_id": "ac", "count": 1.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAggregate_SingleGroupAndMissingValues(t *testing.T) {
+	col := newEventsCollection(t)
+	col.Put(&Document{Fields: map[string]DocumentField{
+		"id":         {Type: DocumentFieldTypeString, Value: "7"},
+		"created_at": {Type: DocumentFieldTypeString, Value: "yesterday"},
+	}})
+
+	got := aggregate(t, col, AggregateParams{
+		Accumulators: []Accumulator{
+			{Name: "count", Op: AccumulatorCount},
+			{Name: "total", Op: AccumulatorSum, Field: "created_at"},
+		},
+	})
+	want := []map[string]any{{"count": 7.0, "total": 850.0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	got = aggregate(t, col, AggregateParams{
+		Match:   &Filter{Op: FilterOpIn, Field: "id", Values: []DocumentField{*str("6"), *str("7")}},
+		GroupBy: "tenant",
+		Accumulators: []Accumulator{
+			{Name: "total", Op: AccumulatorSum, Field: "created_at"},
+			{Name: "avg", Op: AccumulatorAvg, Field: "created_at"},
+		},
+	})
+	want = []map[string]any{
+		{"_id": "acme", "total": 0.0},
+		{"total": 0.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAggregate_Invalid(t *testing.T) {
+	col := newEventsCollection(t)
+
+	invalid := []AggregateParams{
+		{Accumulators: []Accumulator{{Name: "", Op: AccumulatorCount}}},
+		{Accumulators: []Accumulator{{Name: "_id", Op: AccumulatorCount}}},
+		{Accumulators: []Accumulator{{Name: "n", Op: AccumulatorCount}, {Name: "n", Op: AccumulatorCount}}},
+		{Accumulators: []Accumulator{{Name: "total", Op: AccumulatorSum}}},
+		{Accumulators: []Accumulator{{Name: "median", Op: "median", Field: "created_at"}}},
+		{Limit: -1},
+	}
+	for _, params := range invalid {
+		if _, err := col.Aggregate(params); err != ErrInvalidAggregation {
+			t.Fatalf("expected ErrInvalidAggregation for %+v, got %v", params, err)
+		}
+	}
+	if _, err := col.Aggregate(AggregateParams{Match: &Filter{Op: "like"}}); err != ErrInvalidFilter {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package document_store
+
+// PutMany stores docs under a single lock acquisition and a single log
+// record, and returns the key of each document stored, like Put, and one
+// error per document, nil for each one stored. A document that fails does
+// not stop the others and gets an empty key.
+func (c *CollectionImpl) PutMany(docs []*Document) ([]string, []error) {
+	errs := make([]error, len(docs))
+	ops := make([]txOp, len(docs))
+	for i, doc := range docs {
+		prepared, key, err := c.prepare(doc)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		ops[i] = txOp{collection: c, key: key, doc: prepared, source: doc}
+	}
+	c.applyMany(ops, errs)
+	keys := make([]string, len(docs))
+	for i := range ops {
+		if errs[i] == nil {
+			keys[i] = ops[i].key
+		}
+	}
+	return keys, errs
+}
+
+// DeleteMany deletes the documents stored under keys like PutMany stores
+// documents, returning ErrDocumentNotFound for each key with no document.
+func (c *CollectionImpl) DeleteMany(keys []string) []error {
+	errs := make([]error, len(keys))
+	ops := make([]txOp, len(keys))
+	for i, key := range keys {
+		ops[i] = txOp{collection: c, key: key, delete: true}
+	}
+	c.applyMany(ops, errs)
+	return errs
+}
+
+// applyMany applies, in order, the ops whose error is still nil and records
+// the error of each one that fails. The documents and the indexes restore
+// their order once at the end instead of after every write.
+func (c *CollectionImpl) applyMany(ops []txOp, errs []error) {
+	c.lockWrite()
+	defer c.unlockWrite()
+	c.beginBatch()
+	defer c.endBatch()
+
+	undo := make([]txUndo, 0, len(ops))
+	records := make([]walRecord, 0, len(ops))
+	applied := make([]int, 0, len(ops))
+	for i := range ops {
+		if errs[i] != nil {
+			continue
+		}
+		u := c.undoFor(ops[i].key)
+		rec, err := ops[i].apply()
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		undo = append(undo, u)
+		records = append(records, rec)
+		applied = append(applied, i)
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	rec := walRecord{Op: walOpTx, Ops: records}
+	if err := c.log(rec); err != nil {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i].restore()
+		}
+		for _, i := range applied {
+			errs[i] = err
+		}
+		return
+	}
+	for n, i := range applied {
+		if ops[i].source != nil {
+			ops[i].source.written(records[n].Doc)
+		}
+		c.notify(undo[n].key, c.live(undo[n].doc), records[n].Doc)
+	}
+	c.evict(rec.writtenKeys(c.name)...)
+}
@@ -0,0 +1,136 @@
+package document_store
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestPutMany_PerItemResults(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("name", IndexOptions{Fields: []string{"name"}, Unique: true})
+	col.Put(userDoc("0", "Zed"))
+
+	docs := []*Document{
+		userDoc("2", "Bob"),
+		nil,
+		userDoc("3", "Zed"),
+		{Fields: map[string]DocumentField{"name": {Type: DocumentFieldTypeString, Value: "NoKey"}}},
+		userDoc("1", "Alice"),
+		userDoc("4", "Alice"),
+	}
+	keys, errs := col.PutMany(docs)
+	want := []error{nil, ErrUnsupportedDocumentField, ErrUniqueConstraintViolation, ErrUnsupportedDocumentField, nil, ErrUniqueConstraintViolation}
+	for i := range want {
+		if !errors.Is(errs[i], want[i]) {
+			t.Fatalf("expected %v for item %d, got %v", want[i], i, errs[i])
+		}
+	}
+	assertIDs(t, keys, "2", "", "", "", "1", "")
+
+	assertIDs(t, queryIDs(t, col, "name", QueryParams{}), "1", "2", "0")
+	if docs[0].Version != 2 || docs[4].Version != 3 {
+		t.Fatalf("expected versions 2 and 3, got %d and %d", docs[0].Version, docs[4].Version)
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("name")
+	col.PutMany([]*Document{userDoc("1", "a"), userDoc("2", "b"), userDoc("3", "c")})
+
+	errs := col.DeleteMany([]string{"2", "missing", "2", "3"})
+	want := []error{nil, ErrDocumentNotFound, ErrDocumentNotFound, nil}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Fatalf("expected %v for item %d, got %v", want[i], i, errs[i])
+		}
+	}
+	assertIDs(t, queryIDs(t, col, "name", QueryParams{}), "1")
+}
+
+func TestPutMany_IndexMatchesSinglePuts(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var docs []*Document
+	for i := 0; i < 500; i++ {
+		docs = append(docs, &Document{Fields: map[string]DocumentField{
+			"id":   {Type: DocumentFieldTypeString, Value: fmt.Sprint(rng.Intn(300))},
+			"n":    {Type: DocumentFieldTypeNumber, Value: float64(rng.Intn(50))},
+			"tags": {Type: DocumentFieldTypeArray, Value: []any{fmt.Sprint(rng.Intn(20)), fmt.Sprint(rng.Intn(20))}},
+		}})
+	}
+	var deletes []string
+	for i := 0; i < 100; i++ {
+		deletes = append(deletes, fmt.Sprint(rng.Intn(300)))
+	}
+
+	newCol := func() *CollectionImpl {
+		col, _ := NewStore().CreateCollection("items", &CollectionConfig{PrimaryKey: "id"})
+		col.CreateIndex("n")
+		col.CreateIndex("tags", IndexOptions{Fields: []string{"tags"}, Multikey: true})
+		return col
+	}
+	single, batched := newCol(), newCol()
+	for _, doc := range docs {
+		single.Put(&Document{Fields: doc.Fields})
+	}
+	for _, key := range deletes {
+		single.Delete(key)
+	}
+	batched.PutMany(docs)
+	batched.DeleteMany(deletes)
+
+	for _, name := range []string{"n", "tags"} {
+		got, want := batched.indexes[name].keys, single.indexes[name].keys
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("expected index %q keys %q, got %q", name, want, got)
+		}
+		assertIDs(t, queryIDs(t, batched, name, QueryParams{}), queryIDs(t, single, name, QueryParams{})...)
+	}
+}
+
+func TestPutMany_ReplayedFromWAL(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStoreFromWAL(dir)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.PutMany([]*Document{userDoc("1", "a"), userDoc("2", "b"), userDoc("3", "c")})
+	col.DeleteMany([]string{"2", "missing"})
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	restored, _ := reopened.GetCollection("users")
+	if !restored.Exists("1") || restored.Exists("2") || !restored.Exists("3") {
+		t.Fatalf("expected documents 1 and 3 after replay, got %v", restored.List())
+	}
+	if v := getVersion(t, restored, "3"); v != 3 {
+		t.Fatalf("expected version 3 after replay, got %d", v)
+	}
+}
+
+func BenchmarkPutMany(b *testing.B) {
+	docs := make([]*Document, 10000)
+	for i := range docs {
+		docs[i] = userDoc(fmt.Sprintf("%05d", i), fmt.Sprintf("name%d", (i*7919)%len(docs)))
+	}
+	b.Run("Put", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+			col.CreateIndex("name")
+			for _, doc := range docs {
+				col.Put(doc)
+			}
+		}
+	})
+	b.Run("PutMany", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+			col.CreateIndex("name")
+			col.PutMany(docs)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package document_store
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+var ErrDocumentTooLarge = errors.New("document larger than the collection")
+
+// capEntry records when a document of a capped collection was inserted and
+// its size in bytes.
+type capEntry struct {
+	seq  uint64
+	size int
+}
+
+// insertion is a position in the insertion order. Entries whose seq no longer
+// matches the document's are left behind by deletes and skipped.
+type insertion struct {
+	key string
+	seq uint64
+}
+
+func (c *CollectionImpl) capped() bool {
+	return c.config.MaxDocuments > 0 || c.config.MaxBytes > 0
+}
+
+// documentSize is the size of doc's fields encoded as JSON.
+func documentSize(doc *Document) int {
+	data, _ := json.Marshal(doc.Fields)
+	return len(data)
+}
+
+// checkSize rejects a document that could not fit even in an empty
+// collection.
+func (c *CollectionImpl) checkSize(doc *Document) error {
+	if c.config.MaxBytes > 0 && documentSize(doc) > c.config.MaxBytes {
+		return ErrDocumentTooLarge
+	}
+	return nil
+}
+
+// track keeps the insertion order and size of the document stored under key
+// up to date. A replaced document keeps its place. Must be called with the
+// write lock held, after the documents map is updated.
+func (c *CollectionImpl) track(key string, doc *Document) {
+	if !c.capped() {
+		return
+	}
+	if c.capEntries == nil {
+		c.capEntries = make(map[string]capEntry)
+	}
+	entry, exists := c.capEntries[key]
+	c.bytes -= entry.size
+	if doc == nil {
+		delete(c.capEntries, key)
+		return
+	}
+	if !exists {
+		c.insertSeq++
+		entry.seq = c.insertSeq
+		c.insertions = append(c.insertions, insertion{key: key, seq: entry.seq})
+	}
+	if c.config.MaxBytes > 0 {
+		entry.size = documentSize(doc)
+	}
+	c.bytes += entry.size
+	c.capEntries[key] = entry
+}
+
+// restoreInsertion puts a document whose insert was undone back at its
+// earlier place in the insertion order.
+func (c *CollectionImpl) restoreInsertion(key string, seq uint64) {
+	entry, exists := c.capEntries[key]
+	if !exists || entry.seq == seq {
+		return
+	}
+	entry.seq = seq
+	c.capEntries[key] = entry
+	i := sort.Search(len(c.insertions), func(i int) bool {
+		return c.insertions[i].seq >= seq
+	})
+	c.insertions = append(c.insertions, insertion{})
+	copy(c.insertions[i+1:], c.insertions[i:])
+	c.insertions[i] = insertion{key: key, seq: seq}
+}
+
+// evict deletes the oldest inserted documents until the collection is within
+// its limits. The documents under the written keys are evicted only once no
+// other document is left: a replaced document keeps its place, and a write
+// that grows it must not evict the document it just stored. Evictions are not
+// logged, since replaying the writes that caused them evicts the same
+// documents. Must be called with the write lock held.
+func (c *CollectionImpl) evict(written ...string) {
+	if !c.capped() {
+		return
+	}
+	if len(written) > 0 {
+		spared := make(map[string]bool, len(written))
+		for _, key := range written {
+			spared[key] = true
+		}
+		c.evictOldest(spared)
+	}
+	c.evictOldest(nil)
+	// Drop the entries left behind by deletes once they outnumber the
+	// documents.
+	if len(c.insertions) > 2*len(c.capEntries)+16 {
+		c.insertions = c.insertionOrder()
+	}
+}
+
+// evictOldest evicts in insertion order, passing over the spared keys, which
+// keep their place.
+func (c *CollectionImpl) evictOldest(spared map[string]bool) {
+	var kept []insertion
+	for len(c.insertions) > 0 && c.overCapacity() {
+		oldest := c.insertions[0]
+		c.insertions = c.insertions[1:]
+		if entry, exists := c.capEntries[oldest.key]; !exists || entry.seq != oldest.seq {
+			continue
+		}
+		if spared[oldest.key] {
+			kept = append(kept, oldest)
+			continue
+		}
+		doc := c.documents[oldest.key]
+		c.setDocument(oldest.key, nil)
+		c.notify(oldest.key, doc, nil)
+	}
+	if len(kept) > 0 {
+		c.insertions = append(kept, c.insertions...)
+	}
+}
+
+func (c *CollectionImpl) overCapacity() bool {
+	return c.config.MaxDocuments > 0 && len(c.documents) > c.config.MaxDocuments ||
+		c.config.MaxBytes > 0 && c.bytes > c.config.MaxBytes
+}
+
+// insertionOrder returns the live entries of the insertion order.
+func (c *CollectionImpl) insertionOrder() []insertion {
+	order := make([]insertion, 0, len(c.capEntries))
+	for _, ins := range c.insertions {
+		if entry, exists := c.capEntries[ins.key]; exists && entry.seq == ins.seq {
+			order = append(order, ins)
+		}
+	}
+	return order
+}
+
+// dumpOrder returns the keys of a capped collection, oldest inserted first.
+func (c *CollectionImpl) dumpOrder() []string {
+	if !c.capped() {
+		return nil
+	}
+	order := c.insertionOrder()
+	keys := make([]string, len(order))
+	for i, ins := range order {
+		keys[i] = ins.key
+	}
+	return keys
+}
+
+// restoreOrder rebuilds the insertion order of a loaded collection from the
+// dumped keys. Documents missing from them follow in version order.
+func (c *CollectionImpl) restoreOrder(order []string) {
+	if !c.capped() {
+		return
+	}
+	listed := make(map[string]bool, len(order))
+	for _, key := range order {
+		if _, exists := c.documents[key]; exists && !listed[key] {
+			listed[key] = true
+			c.track(key, c.documents[key])
+		}
+	}
+	var rest []string
+	for key := range c.documents {
+		if !listed[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		return c.documents[rest[i]].Version < c.documents[rest[j]].Version
+	})
+	for _, key := range rest {
+		c.track(key, c.documents[key])
+	}
+	c.evict()
+}
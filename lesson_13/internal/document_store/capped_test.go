@@ -0,0 +1,172 @@
+package document_store
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func documentKeys(col *CollectionImpl) []string {
+	var keys []string
+	for key := range col.documents {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestCapped_MaxDocumentsEvictsOldestInserted(t *testing.T) {
+	col, _ := NewStore().CreateCollection("audit", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 3})
+	col.CreateIndex("name")
+	sub, _ := col.Watch(WatchOptions{})
+	defer sub.Close()
+
+	for _, id := range []string{"5", "4", "3", "2"} {
+		col.Put(userDoc(id, "user"+id))
+	}
+	if keys := documentKeys(col); !reflect.DeepEqual(keys, []string{"2", "3", "4"}) {
+		t.Fatalf("expected 2, 3 and 4 to remain, got %v", keys)
+	}
+	for range 4 {
+		nextEvent(t, sub)
+	}
+	if event := nextEvent(t, sub); event.Type != ChangeDelete || event.Key != "5" {
+		t.Fatalf("expected delete of 5, got %+v", event)
+	}
+
+	// A replaced document keeps its place.
+	col.Put(userDoc("4", "again"))
+	col.Put(userDoc("1", "user1"))
+	if keys := documentKeys(col); !reflect.DeepEqual(keys, []string{"1", "2", "3"}) {
+		t.Fatalf("expected 1, 2 and 3 to remain, got %v", keys)
+	}
+	if docs, _ := col.Query("name", QueryParams{}); len(docs) != 3 {
+		t.Fatalf("expected the index to hold 3 documents, got %d", len(docs))
+	}
+}
+
+func TestCapped_MaxBytes(t *testing.T) {
+	size := documentSize(userDoc("1", "Alice"))
+	col, _ := NewStore().CreateCollection("audit", &CollectionConfig{PrimaryKey: "id", MaxBytes: 2 * size})
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := col.Put(userDoc(id, "Alice")); err != nil {
+			t.Fatalf("unexpected error on Put: %v", err)
+		}
+	}
+	if keys := documentKeys(col); !reflect.DeepEqual(keys, []string{"2", "3"}) {
+		t.Fatalf("expected 2 and 3 to remain, got %v", keys)
+	}
+	if _, err := col.Put(userDoc("4", strings.Repeat("a", 2*size))); err != ErrDocumentTooLarge {
+		t.Fatalf("expected ErrDocumentTooLarge, got %v", err)
+	}
+}
+
+func TestCapped_GrowingReplacementIsKept(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	size := documentSize(userDoc("1", "Alice"))
+	col, _ := store.CreateCollection("audit", &CollectionConfig{PrimaryKey: "id", MaxBytes: 2*size + 10})
+	col.Put(userDoc("1", "Alice"))
+	col.Put(userDoc("2", "Alice"))
+
+	// Document 1 is the oldest and no longer fits beside document 2.
+	doc, err := col.Update("1", Patch{Set: map[string]DocumentField{"name": *str(strings.Repeat("a", 20))}})
+	if err != nil {
+		t.Fatalf("unexpected error on Update: %v", err)
+	}
+	if stored, err := col.Get("1"); err != nil || !reflect.DeepEqual(stored, doc) {
+		t.Fatalf("expected the updated document to be kept, got %v, %v", stored, err)
+	}
+	if keys := documentKeys(col); !reflect.DeepEqual(keys, []string{"1"}) {
+		t.Fatalf("expected 2 to be evicted, got %v", keys)
+	}
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	restored, _ := reopened.GetCollection("audit")
+	if keys := documentKeys(restored); !reflect.DeepEqual(keys, []string{"1"}) {
+		t.Fatalf("expected replay to keep only 1, got %v", keys)
+	}
+}
+
+func TestCapped_FailedTransactionKeepsOrder(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("audit", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 2})
+	col.Put(userDoc("a", "first"))
+	col.Put(userDoc("b", "second"))
+
+	tx := store.Begin()
+	tx.Delete("audit", "a")
+	tx.Put("audit", userDoc("a", "again"))
+	tx.Delete("audit", "missing")
+	if err := tx.Commit(); err != ErrDocumentNotFound {
+		t.Fatalf("expected ErrDocumentNotFound, got %v", err)
+	}
+
+	col.Put(userDoc("c", "third"))
+	if keys := documentKeys(col); !reflect.DeepEqual(keys, []string{"b", "c"}) {
+		t.Fatalf("expected a to stay the oldest and be evicted, got %v", keys)
+	}
+}
+
+func TestCapped_Dump(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("audit", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 2})
+	col.Put(userDoc("b", "first"))
+	col.Put(userDoc("a", "second"))
+	dump, err := store.Dump()
+	if err != nil {
+		t.Fatalf("unexpected error on Dump: %v", err)
+	}
+
+	loaded, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error loading dump: %v", err)
+	}
+	loadedCol, _ := loaded.GetCollection("audit")
+	if loadedCol.config.MaxDocuments != 2 {
+		t.Fatalf("expected MaxDocuments 2, got %d", loadedCol.config.MaxDocuments)
+	}
+	loadedCol.Put(userDoc("c", "third"))
+	if keys := documentKeys(loadedCol); !reflect.DeepEqual(keys, []string{"a", "c"}) {
+		t.Fatalf("expected b to be evicted first, got %v", keys)
+	}
+}
+
+func TestCapped_ReplayEvictsAfterEachRecord(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	col, _ := store.CreateCollection("audit", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 2})
+	col.Put(userDoc("x", "x"))
+	col.Put(userDoc("y", "y"))
+	tx := store.Begin()
+	tx.Put("audit", userDoc("z", "z"))
+	tx.Delete("audit", "y")
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("unexpected error on Commit: %v", err)
+	}
+	col.PutMany([]*Document{userDoc("v", "v"), userDoc("w", "w")})
+	want := documentKeys(col)
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	replayed, _ := reopened.GetCollection("audit")
+	if keys := documentKeys(replayed); !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected %v after replay, got %v", want, keys)
+	}
+}
@@ -0,0 +1,57 @@
+package document_store
+
+import "time"
+
+// Stored documents are never handed out. Writes store a normalized copy of
+// the caller's document and every read returns a deep copy, so a caller
+// changing a document it holds cannot change the store or its indexes.
+
+// cloneDocument returns a deep copy of a stored document.
+func cloneDocument(doc *Document) *Document {
+	return &Document{Fields: cloneFields(doc.Fields), Version: doc.Version, ExpiresAt: cloneTime(doc.ExpiresAt)}
+}
+
+func cloneFields(fields map[string]DocumentField) map[string]DocumentField {
+	if fields == nil {
+		return nil
+	}
+	cloned := make(map[string]DocumentField, len(fields))
+	for name, field := range fields {
+		cloned[name] = DocumentField{Type: field.Type, Value: cloneValue(field.Value)}
+	}
+	return cloned
+}
+
+// cloneValue copies the arrays and objects of a normalized value; the other
+// values are immutable.
+func cloneValue(value any) any {
+	switch v := value.(type) {
+	case []any:
+		array := make([]any, len(v))
+		for i, element := range v {
+			array[i] = cloneValue(element)
+		}
+		return array
+	case map[string]any:
+		object := make(map[string]any, len(v))
+		for name, child := range v {
+			object[name] = cloneValue(child)
+		}
+		return object
+	}
+	return value
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
+
+// written sets the version and expiry the copy of doc was stored with.
+func (doc *Document) written(stored *Document) {
+	doc.Version = stored.Version
+	doc.ExpiresAt = cloneTime(stored.ExpiresAt)
+}
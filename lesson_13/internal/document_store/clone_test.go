@@ -0,0 +1,156 @@
+package document_store
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func profileDoc(id, name string) *Document {
+	doc := userDoc(id, name)
+	doc.Fields["tags"] = DocumentField{Type: DocumentFieldTypeArray, Value: []any{"a", "b"}}
+	doc.Fields["address"] = DocumentField{Type: DocumentFieldTypeObject, Value: map[string]any{
+		"city": "Kyiv",
+		"geo":  []any{50.4, 30.5},
+	}}
+	return doc
+}
+
+// mutate changes every level of doc the way a careless caller might.
+func mutate(doc *Document) {
+	doc.Fields["tags"].Value.([]any)[0] = "changed"
+	address := doc.Fields["address"].Value.(map[string]any)
+	address["city"] = "changed"
+	address["geo"].([]any)[0] = 0.0
+	doc.Fields["name"] = *str("changed")
+	doc.Fields["extra"] = *str("changed")
+	doc.Version = 99
+}
+
+func newProfiles(t *testing.T) *CollectionImpl {
+	t.Helper()
+	col, _ := NewStore().CreateCollection("profiles", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("name")
+	if _, err := col.Put(profileDoc("1", "Alice")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	return col
+}
+
+func assertUnchanged(t *testing.T, col *CollectionImpl, want *Document) {
+	t.Helper()
+	got, _ := col.Get("1")
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the stored document %v, got %v", want, got)
+	}
+	assertIDs(t, queryIDs(t, col, "name", QueryParams{MinValue: str("Alice"), MaxValue: str("Alice")}), "1")
+}
+
+func TestClone_PutStoresCopy(t *testing.T) {
+	col, _ := NewStore().CreateCollection("profiles", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("name")
+	doc := profileDoc("1", "Alice")
+	if _, err := col.Put(doc); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	if doc.Version != 1 {
+		t.Fatalf("expected Put to set the version of the caller's document, got %d", doc.Version)
+	}
+	mutate(doc)
+	assertUnchanged(t, col, &Document{Fields: profileDoc("1", "Alice").Fields, Version: 1})
+
+	tx := col.store.Begin()
+	txDoc := profileDoc("2", "Bob")
+	tx.Put("profiles", txDoc)
+	mutate(txDoc)
+	tx.Commit()
+	if stored, _ := col.Get("2"); stored.Fields["name"].Value != "Bob" {
+		t.Fatalf("expected the transaction to store the document as buffered, got %v", stored)
+	}
+	if txDoc.Version != 2 {
+		t.Fatalf("expected Commit to set the version of the caller's document, got %d", txDoc.Version)
+	}
+}
+
+func TestClone_ReadsReturnCopies(t *testing.T) {
+	reads := []struct {
+		name string
+		read func(col *CollectionImpl) []Document
+	}{
+		{"Get", func(col *CollectionImpl) []Document {
+			doc, _ := col.Get("1")
+			return []Document{*doc}
+		}},
+		{"List", func(col *CollectionImpl) []Document { return col.List() }},
+		{"Query", func(col *CollectionImpl) []Document {
+			docs, _ := col.Query("name", QueryParams{})
+			return docs
+		}},
+		{"Find", func(col *CollectionImpl) []Document {
+			docs, _ := col.Find(Filter{Op: FilterOpEq, Field: "name", Value: str("Alice")})
+			return docs
+		}},
+		{"Update", func(col *CollectionImpl) []Document {
+			doc, _ := col.Update("1", Patch{Set: map[string]DocumentField{"name": *str("Alice")}})
+			return []Document{*doc}
+		}},
+	}
+	for _, tt := range reads {
+		col := newProfiles(t)
+		docs := tt.read(col)
+		if len(docs) != 1 {
+			t.Fatalf("%s: expected 1 document, got %v", tt.name, docs)
+		}
+		want, _ := col.Get("1")
+		mutate(&docs[0])
+		assertUnchanged(t, col, want)
+	}
+}
+
+func TestClone_WatchEventsAreCopies(t *testing.T) {
+	col := newProfiles(t)
+	sub, _ := col.Watch(WatchOptions{})
+	defer sub.Close()
+	other, _ := col.Watch(WatchOptions{})
+	defer other.Close()
+	col.Update("1", Patch{Set: map[string]DocumentField{"age": *number(30)}})
+	want, _ := col.Get("1")
+
+	event := <-sub.Events()
+	mutate(event.OldDoc)
+	mutate(event.NewDoc)
+	assertUnchanged(t, col, want)
+	if otherEvent := <-other.Events(); !reflect.DeepEqual(otherEvent.NewDoc, want) {
+		t.Fatalf("expected each subscriber to get its own copy, got %v", otherEvent.NewDoc)
+	}
+}
+
+func BenchmarkCopyOnRead(b *testing.B) {
+	col, _ := NewStore().CreateCollection("profiles", &CollectionConfig{PrimaryKey: "id"})
+	for i := 0; i < 1000; i++ {
+		col.Put(profileDoc(fmt.Sprintf("%04d", i), fmt.Sprintf("name%d", i)))
+	}
+	stored := col.documents["0000"]
+
+	b.Run("Clone", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			cloneDocument(stored)
+		}
+	})
+	b.Run("Get", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			col.Get("0500")
+		}
+	})
+	b.Run("List", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			col.List()
+		}
+	})
+	b.Run("Put", func(b *testing.B) {
+		doc := profileDoc("0500", "name500")
+		for n := 0; n < b.N; n++ {
+			col.Put(doc)
+		}
+	})
+}
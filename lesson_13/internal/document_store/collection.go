@@ -4,136 +4,319 @@ import (
 	"errors"
 	"sort"
 	"sync"
+	"time"
 )
 
 var ErrIndexAlreadyExists = errors.New("index already exists")
 var ErrIndexNotFound = errors.New("index not found")
 
 type Collection interface {
-	Put(doc *Document) error
+	Put(doc *Document) (string, error)
 	Get(key string) (*Document, error)
+	GetWithProjection(key string, projection *Projection) (*Document, error)
 	Delete(key string) error
 	List() []Document
-	CreateIndex(fieldName string) error
+	ListPage(params ListParams) (*Page, error)
+	CreateIndex(name string, opts ...IndexOptions) error
 	DeleteIndex(fieldName string) error
 	Query(fieldName string, params QueryParams) ([]Document, error)
-}
-
-type index struct {
-	data map[string][]*Document
-	keys []string
+	QueryPage(fieldName string, params QueryParams) (*Page, error)
+	Find(filter Filter) ([]Document, error)
+	Aggregate(params AggregateParams) ([]Document, error)
+	Count(filter Filter) (int, error)
+	Exists(key string) bool
+	Update(key string, patch Patch) (*Document, error)
+	PutIfVersion(doc *Document, version uint64) (string, error)
+	DeleteIfVersion(key string, version uint64) error
+	PutMany(docs []*Document) ([]string, []error)
+	DeleteMany(keys []string) []error
 }
 
 type CollectionImpl struct {
+	name      string
+	store     *Store
 	mu        sync.RWMutex
+	dropped   bool
 	documents map[string]*Document
 	config    CollectionConfig
 	indexes   map[string]*index
+	// ordered holds the documents in primary key order. During a batch it is
+	// only sorted by endBatch.
+	ordered  docList
+	batching bool
+	// version is the last version given to a document.
+	version     uint64
+	subscribers map[*Subscription]struct{}
+
+	// The insertion order and total size of a capped collection.
+	capEntries map[string]capEntry
+	insertions []insertion
+	insertSeq  uint64
+	bytes      int
+
+	keys keyGenerator
 }
 
+// CollectionConfig describes the documents a collection holds.
+//
+// PrimaryKey names the field holding the key of each document, a string or,
+// with NumericKey, a number. KeyFields instead names the fields of a
+// composite key, each holding a string or a number. Keys are passed around
+// in the canonical form of EncodeKey, and documents are ordered by key
+// value, so numeric keys sort as numbers.
+//
+// GenerateKeys gives a document stored without a primary key a new UUIDv7
+// key. Generated keys sort in the order they were generated.
+//
+// TTL makes documents expire that long after they are written. With TTLField
+// set, they expire TTL after the Unix time in seconds held by that number
+// field instead, and never if it is missing.
+//
+// MaxDocuments and MaxBytes cap the collection: a write that takes it past
+// either limit evicts the oldest inserted documents. A replaced document
+// keeps its place, but the write that replaced it evicts other documents
+// first. Bytes are counted as the JSON encoding of the document fields.
+//
+// Schema, when set, is checked by every write.
 type CollectionConfig struct {
-	PrimaryKey string
+	PrimaryKey   string
+	NumericKey   bool
+	KeyFields    []string
+	GenerateKeys bool
+	TTL          time.Duration
+	TTLField     string
+	MaxDocuments int
+	MaxBytes     int
+	Schema       *Schema
 }
 
+// QueryParams bounds are inclusive. A single bound only matches values of
+// its own type; with no bounds every indexed value matches.
+// On a compound index Prefix holds equality values for the leading fields
+// and the bounds apply to the field after them.
 type QueryParams struct {
-	Desc     bool
-	MinValue *string
-	MaxValue *string
+	PageParams
+	Desc       bool
+	Prefix     []DocumentField
+	MinValue   *DocumentField
+	MaxValue   *DocumentField
+	Projection *Projection
 }
 
 var _ Collection = (*CollectionImpl)(nil)
 
-func (c *CollectionImpl) Put(doc *Document) error {
-	if doc == nil {
-		return ErrUnsupportedDocumentField
+// Put stores a copy of doc, replacing the document with the same key, sets
+// doc.Version to the version it is stored with and returns the key, which
+// is also added to doc if it was generated.
+func (c *CollectionImpl) Put(doc *Document) (string, error) {
+	prepared, key, err := c.prepare(doc)
+	if err != nil {
+		return "", err
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if keyField, exists := doc.Fields[c.config.PrimaryKey]; exists && keyField.Type == DocumentFieldTypeString {
-		if key, ok := keyField.Value.(string); ok {
-			oldDoc := c.documents[key]
-			c.documents[key] = doc
-
-			for fieldName, idx := range c.indexes {
-				if oldDoc != nil {
-					c.removeFromIndex(idx, fieldName, oldDoc)
-				}
-				c.addToIndex(idx, fieldName, doc)
-			}
-			return nil
+	c.lockWrite()
+	defer c.unlockWrite()
+	if err := c.put(key, prepared); err != nil {
+		return "", err
+	}
+	doc.written(prepared)
+	return key, nil
+}
+
+// put stores doc under key and sets its version. doc must not be shared with
+// the caller. Must be called with the write lock held.
+func (c *CollectionImpl) put(key string, doc *Document) error {
+	if err := c.checkWrite(key, doc); err != nil {
+		return err
+	}
+	version := c.version + 1
+	expiresAt := c.expiresAt(doc)
+	if err := c.log(walRecord{Op: walOpPut, Key: key, Doc: &Document{Fields: doc.Fields, Version: version, ExpiresAt: expiresAt}}); err != nil {
+		return err
+	}
+	c.version = version
+	doc.Version = version
+	doc.ExpiresAt = expiresAt
+	oldDoc := c.live(c.documents[key])
+	c.setDocument(key, doc)
+	c.notify(key, oldDoc, doc)
+	if !c.replaying() {
+		c.evict(key)
+	}
+	return nil
+}
+
+// checkWrite checks doc against the schema, unique indexes and size limits
+// before it is stored under key. Replayed writes passed these checks when
+// they were logged, and are not checked again: nothing expires during
+// replay, so a document a write was allowed to replace as expired would
+// still conflict with it. Must be called with the write lock held.
+func (c *CollectionImpl) checkWrite(key string, doc *Document) error {
+	if c.replaying() {
+		return nil
+	}
+	if err := c.checkSchema(doc); err != nil {
+		return err
+	}
+	if err := c.checkUnique(key, doc); err != nil {
+		return err
+	}
+	return c.checkSize(doc)
+}
+
+// setDocument replaces the document stored under key, or removes it when doc
+// is nil, and updates the indexes. Must be called with the write lock held.
+func (c *CollectionImpl) setDocument(key string, doc *Document) {
+	if oldDoc, exists := c.documents[key]; exists {
+		for _, idx := range c.indexes {
+			idx.remove(oldDoc)
 		}
+		c.ordered.remove(oldDoc)
+		delete(c.documents, key)
+	}
+	if doc != nil {
+		doc.order = c.orderKey(doc)
+		c.documents[key] = doc
+		c.ordered.add(doc, c.batching)
+		for _, idx := range c.indexes {
+			idx.add(doc)
+		}
+	}
+	c.track(key, doc)
+}
+
+// beginBatch defers restoring the order of the documents and of the index
+// keys until endBatch, so that a series of writes sorts them only once.
+func (c *CollectionImpl) beginBatch() {
+	c.batching = true
+	for _, idx := range c.indexes {
+		idx.beginBatch()
 	}
-	return ErrUnsupportedDocumentField
 }
 
+func (c *CollectionImpl) endBatch() {
+	c.batching = false
+	c.ordered.sort()
+	for _, idx := range c.indexes {
+		idx.endBatch()
+	}
+}
+
+// Get returns a copy of the document stored under key.
 func (c *CollectionImpl) Get(key string) (*Document, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	doc, exists := c.documents[key]
-	if !exists {
+	doc := c.live(c.documents[key])
+	if doc == nil {
 		return nil, ErrDocumentNotFound
 	}
-	return doc, nil
+	return cloneDocument(doc), nil
+}
+
+// GetWithProjection returns a copy of the document holding only the fields
+// selected by projection.
+func (c *CollectionImpl) GetWithProjection(key string, projection *Projection) (*Document, error) {
+	if err := projection.validate(); err != nil {
+		return nil, err
+	}
+	doc, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	projected := projection.apply(*doc, c.keyFields())
+	return &projected, nil
 }
 
 func (c *CollectionImpl) Delete(key string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	doc, exists := c.documents[key]
-	if !exists {
+	c.lockWrite()
+	defer c.unlockWrite()
+	return c.delete(key)
+}
+
+// delete must be called with the write lock held.
+func (c *CollectionImpl) delete(key string) error {
+	doc := c.live(c.documents[key])
+	if doc == nil {
 		return ErrDocumentNotFound
 	}
-
-	for fieldName, idx := range c.indexes {
-		c.removeFromIndex(idx, fieldName, doc)
+	if err := c.log(walRecord{Op: walOpDelete, Key: key}); err != nil {
+		return err
 	}
-
-	delete(c.documents, key)
+	c.setDocument(key, nil)
+	c.notify(key, doc, nil)
 	return nil
 }
 
-func (c *CollectionImpl) List() []Document {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	docs := make([]Document, 0, len(c.documents))
-	for _, doc := range c.documents {
-		docs = append(docs, *doc)
+// CreateIndex indexes the field called name, or the fields listed in the
+// options, in which case name only identifies the index.
+func (c *CollectionImpl) CreateIndex(name string, opts ...IndexOptions) error {
+	var options IndexOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if len(options.Fields) == 0 {
+		options.Fields = []string{name}
 	}
-	return docs
-}
 
-func (c *CollectionImpl) CreateIndex(fieldName string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if _, exists := c.indexes[fieldName]; exists {
+	c.lockWrite()
+	defer c.unlockWrite()
+	if _, exists := c.indexes[name]; exists {
 		return ErrIndexAlreadyExists
 	}
 
-	idx := &index{
-		data: make(map[string][]*Document),
-		keys: make([]string, 0),
-	}
+	idx := newIndex(options)
+	idx.beginBatch()
 	for _, doc := range c.documents {
-		c.addToIndex(idx, fieldName, doc)
+		idx.add(doc)
+	}
+	idx.endBatch()
+	if options.Unique && !c.replaying() {
+		if err := c.uniqueConflicts(name, idx); err != nil {
+			return err
+		}
+	}
+	if err := c.log(walRecord{Op: walOpCreateIndex, FieldName: name, Index: &options}); err != nil {
+		return err
 	}
 
-	sort.Strings(idx.keys)
-
-	c.indexes[fieldName] = idx
+	c.indexes[name] = idx
 	return nil
 }
 
 func (c *CollectionImpl) DeleteIndex(fieldName string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.lockWrite()
+	defer c.unlockWrite()
 	if _, exists := c.indexes[fieldName]; !exists {
 		return ErrIndexNotFound
 	}
+	if err := c.log(walRecord{Op: walOpDeleteIndex, FieldName: fieldName}); err != nil {
+		return err
+	}
 	delete(c.indexes, fieldName)
 	return nil
 }
 
 func (c *CollectionImpl) Query(fieldName string, params QueryParams) ([]Document, error) {
+	page, err := c.QueryPage(fieldName, params)
+	if err != nil {
+		return nil, err
+	}
+	return page.Docs, nil
+}
+
+// QueryPage returns the documents in index order, ties broken by primary
+// key, starting after params.Cursor.
+func (c *CollectionImpl) QueryPage(fieldName string, params QueryParams) (*Page, error) {
+	if err := params.PageParams.validate(); err != nil {
+		return nil, err
+	}
+	if err := params.Projection.validate(); err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(params.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	idx, exists := c.indexes[fieldName]
@@ -141,72 +324,85 @@ func (c *CollectionImpl) Query(fieldName string, params QueryParams) ([]Document
 		return nil, ErrIndexNotFound
 	}
 
-	startIdx := 0
-	endIdx := len(idx.keys)
-
-	if params.MinValue != nil {
-		startIdx = sort.Search(len(idx.keys), func(i int) bool {
-			return idx.keys[i] >= *params.MinValue
-		})
+	startIdx, endIdx, err := idx.bounds(params)
+	if err != nil {
+		return nil, err
 	}
-
-	if params.MaxValue != nil {
-		endIdx = sort.Search(len(idx.keys), func(i int) bool {
-			return idx.keys[i] > *params.MaxValue
-		})
+	if startIdx == endIdx {
+		return &Page{}, nil
+	}
+	first, last := idx.keys[startIdx], idx.keys[endIdx-1]
+	inRange := func(key string) bool {
+		return key >= first && key <= last
 	}
 
-	keys := make([]string, endIdx-startIdx)
-	copy(keys, idx.keys[startIdx:endIdx])
-
-	if params.Desc {
-		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
-			keys[i], keys[j] = keys[j], keys[i]
+	// Resume at the cursor's index key.
+	if after != nil {
+		pos := sort.SearchStrings(idx.keys, after.Key)
+		if !params.Desc {
+			startIdx = max(startIdx, pos)
+		} else {
+			if pos < len(idx.keys) && idx.keys[pos] == after.Key {
+				pos++
+			}
+			endIdx = min(endIdx, pos)
 		}
 	}
 
-	var result []Document
-	for _, key := range keys {
-		for _, doc := range idx.data[key] {
-			result = append(result, *doc)
+	pager := newPager(params.PageParams)
+	for n := startIdx; n < endIdx; n++ {
+		i := n
+		if params.Desc {
+			i = startIdx + endIdx - 1 - n
+		}
+		key := idx.keys[i]
+
+		docs := idx.docs(key)
+		if after != nil && key == after.Key {
+			docs = resume(docs, after.ID, params.Desc)
+		}
+		for n := range docs {
+			doc := docs[n]
+			if params.Desc {
+				doc = docs[len(docs)-1-n]
+			}
+			if c.expired(doc) {
+				continue
+			}
+			// A multikey index can hold a document under several keys in
+			// the range; it is returned at the first of them.
+			if idx.options.Multikey && firstKey(idx.keysFor(doc), inRange, params.Desc) != key {
+				continue
+			}
+			if !pager.add(doc, cursor{Key: key, ID: doc.order}) {
+				return c.project(pager.page(), params.Projection), nil
+			}
 		}
 	}
+	return c.project(pager.page(), params.Projection), nil
+}
 
-	return result, nil
+// lockWrite takes the store's commit lock before the collection lock so that
+// a concurrent Dump never observes a mutation that is not yet in the log.
+func (c *CollectionImpl) lockWrite() {
+	if c.store != nil {
+		c.store.commitMu.RLock()
+	}
+	c.mu.Lock()
 }
 
-func (c *CollectionImpl) addToIndex(idx *index, fieldName string, doc *Document) {
-	if field, exists := doc.Fields[fieldName]; exists && field.Type == DocumentFieldTypeString {
-		if value, ok := field.Value.(string); ok {
-			if _, exists := idx.data[value]; !exists {
-				insertPos := sort.SearchStrings(idx.keys, value)
-				idx.keys = append(idx.keys, "")
-				copy(idx.keys[insertPos+1:], idx.keys[insertPos:])
-				idx.keys[insertPos] = value
-			}
-			idx.data[value] = append(idx.data[value], doc)
-		}
+func (c *CollectionImpl) unlockWrite() {
+	c.mu.Unlock()
+	if c.store != nil {
+		c.store.commitMu.RUnlock()
 	}
 }
 
-func (c *CollectionImpl) removeFromIndex(idx *index, fieldName string, doc *Document) {
-	if field, exists := doc.Fields[fieldName]; exists && field.Type == DocumentFieldTypeString {
-		if value, ok := field.Value.(string); ok {
-			docs := idx.data[value]
-			for i, d := range docs {
-				if d == doc {
-					idx.data[value] = append(docs[:i], docs[i+1:]...)
-					if len(idx.data[value]) == 0 {
-						delete(idx.data, value)
-						keyPos := sort.SearchStrings(idx.keys, value)
-						if keyPos < len(idx.keys) && idx.keys[keyPos] == value {
-							copy(idx.keys[keyPos:], idx.keys[keyPos+1:])
-							idx.keys = idx.keys[:len(idx.keys)-1]
-						}
-					}
-					break
-				}
-			}
-		}
+// log must be called with the write lock held.
+func (c *CollectionImpl) log(rec walRecord) error {
+	if c.store == nil || c.dropped {
+		return nil
 	}
+	rec.Collection = c.name
+	return c.store.wal.append(rec)
 }
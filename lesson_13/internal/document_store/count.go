@@ -0,0 +1,64 @@
+package document_store
+
+// Count returns the number of documents matching filter. A comparison, "in"
+// or "prefix" filter on a field with a single-field index holding one key per
+// document is answered from the sizes of the index entries; other filters
+// are counted without copying the documents.
+func (c *CollectionImpl) Count(filter Filter) (int, error) {
+	if err := filter.validate(); err != nil {
+		return 0, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Expired documents stay in the indexes until they are reaped.
+	if !c.expires() {
+		if filter.Op == FilterOpAnd && len(filter.Filters) == 0 {
+			return len(c.documents), nil
+		}
+		if n, ok := c.indexCount(&filter); ok {
+			return n, nil
+		}
+	}
+	return len(c.matching(&filter)), nil
+}
+
+// indexCount counts the documents matching a leaf filter from an index, or
+// returns false if no index gives an exact count. Must be called with the
+// read lock held.
+func (c *CollectionImpl) indexCount(f *Filter) (int, bool) {
+	ranges, ok := f.keyRanges()
+	if !ok {
+		return 0, false
+	}
+	idx := c.indexFor(f.Field)
+	// A multikey index holds a document under every element of its array.
+	if idx == nil || idx.options.Multikey {
+		return 0, false
+	}
+
+	// The ranges of "gt" and "lt" include the bound itself.
+	var exclude string
+	if f.Op == FilterOpGt || f.Op == FilterOpLt {
+		exclude, _ = encodeIndexValue(*f.Value)
+	}
+	n := 0
+	seen := make(map[string]bool)
+	for _, r := range ranges {
+		startIdx, endIdx := idx.span(r[0], r[1])
+		for _, key := range idx.keys[startIdx:endIdx] {
+			if key != exclude && !seen[key] {
+				seen[key] = true
+				n += len(idx.docs(key))
+			}
+		}
+	}
+	return n, true
+}
+
+// Exists reports whether a document is stored under key.
+func (c *CollectionImpl) Exists(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.live(c.documents[key]) != nil
+}
@@ -0,0 +1,89 @@
+package document_store
+
+import (
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	filters := []struct {
+		filter Filter
+		want   int
+	}{
+		{Filter{Op: FilterOpAnd}, 5},
+		{Filter{Op: FilterOpEq, Field: "age", Value: number(25)}, 1},
+		{Filter{Op: FilterOpGt, Field: "age", Value: number(25)}, 2},
+		{Filter{Op: FilterOpGte, Field: "age", Value: number(25)}, 3},
+		{Filter{Op: FilterOpLt, Field: "age", Value: number(9)}, 1},
+		{Filter{Op: FilterOpLte, Field: "age", Value: number(9)}, 2},
+		{Filter{Op: FilterOpIn, Field: "age", Values: []DocumentField{*number(25), *number(25), *number(9)}}, 2},
+		{Filter{Op: FilterOpGt, Field: "age", Value: str("")}, 0},
+		{Filter{Op: FilterOpEq, Field: "active", Value: boolean(true)}, 3},
+		{Filter{Op: FilterOpAnd, Filters: []Filter{
+			{Op: FilterOpEq, Field: "active", Value: boolean(true)},
+			{Op: FilterOpLt, Field: "age", Value: number(50)},
+		}}, 2},
+	}
+
+	plain := newPeopleCollection(t)
+	indexed := newPeopleCollection(t)
+	indexed.CreateIndex("age")
+	indexed.CreateIndex("active")
+	for _, col := range []*CollectionImpl{plain, indexed} {
+		for _, tt := range filters {
+			got, err := col.Count(tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected error on Count(%+v): %v", tt.filter, err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected Count(%+v) = %d, got %d", tt.filter, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestCount_Prefix(t *testing.T) {
+	col := newProductsCollection(t)
+	col.CreateIndex("name")
+
+	got, err := col.Count(Filter{Op: FilterOpPrefix, Field: "name", Value: str("b")})
+	if err != nil {
+		t.Fatalf("unexpected error on Count: %v", err)
+	}
+	if got != 3 {
+		t.Fatalf("expected 3, got %d", got)
+	}
+}
+
+func TestCount_MultikeyCountsDocumentsOnce(t *testing.T) {
+	col := newProductsCollection(t)
+	col.CreateIndex("tags", IndexOptions{Fields: []string{"tags"}, Multikey: true})
+
+	got, err := col.Count(Filter{Op: FilterOpIn, Field: "tags", Values: []DocumentField{*str("fruit"), *str("red")}})
+	if err != nil {
+		t.Fatalf("unexpected error on Count: %v", err)
+	}
+	if got != 3 {
+		t.Fatalf("expected 3, got %d", got)
+	}
+}
+
+func TestCount_InvalidFilter(t *testing.T) {
+	col := newPeopleCollection(t)
+	if _, err := col.Count(Filter{Op: FilterOpEq, Field: "age"}); err != ErrInvalidFilter {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	col := newPeopleCollection(t)
+	if !col.Exists("1") {
+		t.Fatalf("expected document 1 to exist")
+	}
+	col.Delete("1")
+	if col.Exists("1") {
+		t.Fatalf("expected deleted document 1 not to exist")
+	}
+	if col.Exists("missing") {
+		t.Fatalf("expected missing document not to exist")
+	}
+}
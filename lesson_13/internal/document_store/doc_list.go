@@ -0,0 +1,61 @@
+package document_store
+
+import (
+	"slices"
+	"sort"
+)
+
+// docList holds documents in primary key order, compared by their cached
+// order keys. During a batch documents are appended as they come and the
+// list is sorted once when the batch ends.
+type docList struct {
+	docs     []*Document
+	unsorted bool
+}
+
+// search returns the position of the first document whose order key is not
+// below id.
+func (l *docList) search(id string) int {
+	return sort.Search(len(l.docs), func(i int) bool { return l.docs[i].order >= id })
+}
+
+// add reports whether doc left the list out of order, which happens only
+// during a batch.
+func (l *docList) add(doc *Document, batching bool) bool {
+	if !batching {
+		l.docs = slices.Insert(l.docs, l.search(doc.order), doc)
+		return false
+	}
+	wasSorted := !l.unsorted
+	if n := len(l.docs); n > 0 && l.docs[n-1].order > doc.order {
+		l.unsorted = true
+	}
+	l.docs = append(l.docs, doc)
+	return wasSorted && l.unsorted
+}
+
+func (l *docList) remove(doc *Document) {
+	i := l.search(doc.order)
+	if l.unsorted {
+		i = slices.Index(l.docs, doc)
+	}
+	if i >= 0 && i < len(l.docs) && l.docs[i] == doc {
+		l.docs = slices.Delete(l.docs, i, i+1)
+	}
+}
+
+func (l *docList) sort() {
+	if l.unsorted {
+		sort.Slice(l.docs, func(i, j int) bool { return l.docs[i].order < l.docs[j].order })
+		l.unsorted = false
+	}
+}
+
+// resume returns the part of docs, which are in primary key order, that a
+// scan in the given direction reaches after the document with order key id.
+func resume(docs []*Document, id string, desc bool) []*Document {
+	if desc {
+		return docs[:sort.Search(len(docs), func(i int) bool { return docs[i].order >= id })]
+	}
+	return docs[sort.Search(len(docs), func(i int) bool { return docs[i].order > id }):]
+}
@@ -1,5 +1,11 @@
 package document_store
 
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
 type DocumentFieldType string
 
 const (
@@ -15,6 +21,87 @@ type DocumentField struct {
 	Value any
 }
 
+// Document.Version is set by the collection on every write. Versions
+// increase across the whole collection, so a document deleted and stored
+// again never gets back an earlier version. Document.ExpiresAt is set from
+// the collection's TTL settings.
 type Document struct {
-	Fields map[string]DocumentField
+	Fields    map[string]DocumentField
+	Version   uint64     `json:",omitempty"`
+	ExpiresAt *time.Time `json:",omitempty"`
+
+	// order caches orderKey for a stored document.
+	order string
+}
+
+// lookupField resolves a dotted path such as "address.city" through nested
+// object fields. A top-level field whose name contains dots takes priority.
+func lookupField(doc *Document, path string) (DocumentField, bool) {
+	if field, exists := doc.Fields[path]; exists {
+		return field, true
+	}
+	name, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		return DocumentField{}, false
+	}
+	field, exists := doc.Fields[name]
+	if !exists || field.Type != DocumentFieldTypeObject {
+		return DocumentField{}, false
+	}
+
+	value := field.Value
+	for _, part := range strings.Split(rest, ".") {
+		switch object := value.(type) {
+		case map[string]any:
+			value, exists = object[part]
+		case map[string]DocumentField:
+			var child DocumentField
+			child, exists = object[part]
+			value = child
+		default:
+			exists = false
+		}
+		if !exists {
+			return DocumentField{}, false
+		}
+	}
+	return fieldFromValue(value)
+}
+
+// fieldFromValue infers the field type of a value nested inside an object,
+// as produced by JSON decoding.
+func fieldFromValue(value any) (DocumentField, bool) {
+	switch v := value.(type) {
+	case DocumentField:
+		return v, true
+	case string:
+		return DocumentField{Type: DocumentFieldTypeString, Value: v}, true
+	case bool:
+		return DocumentField{Type: DocumentFieldTypeBool, Value: v}, true
+	case []any:
+		return DocumentField{Type: DocumentFieldTypeArray, Value: v}, true
+	case map[string]any, map[string]DocumentField:
+		return DocumentField{Type: DocumentFieldTypeObject, Value: v}, true
+	}
+	if _, ok := numberValue(value); ok {
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: value}, true
+	}
+	return DocumentField{}, false
+}
+
+// arrayElements returns the elements of an array field value, which is
+// []any after JSON decoding but may be any slice type when set from Go.
+func arrayElements(value any) []any {
+	if elements, ok := value.([]any); ok {
+		return elements
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil
+	}
+	elements := make([]any, v.Len())
+	for i := range elements {
+		elements[i] = v.Index(i).Interface()
+	}
+	return elements
 }
@@ -0,0 +1,87 @@
+package document_store
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func addressDoc(id string, address any) *Document {
+	doc := &Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: id},
+	}}
+	if address != nil {
+		doc.Fields["address"] = DocumentField{Type: DocumentFieldTypeObject, Value: address}
+	}
+	return doc
+}
+
+func TestLookupField(t *testing.T) {
+	doc := addressDoc("1", map[string]any{
+		"city": "Kyiv",
+		"geo":  map[string]any{"lat": 50.45, "verified": true},
+	})
+	doc.Fields["a.b"] = DocumentField{Type: DocumentFieldTypeString, Value: "literal"}
+
+	tests := []struct {
+		path      string
+		wantType  DocumentFieldType
+		wantValue any
+		wantFound bool
+	}{
+		{"id", DocumentFieldTypeString, "1", true},
+		{"address.city", DocumentFieldTypeString, "Kyiv", true},
+		{"address.geo.lat", DocumentFieldTypeNumber, 50.45, true},
+		{"address.geo.verified", DocumentFieldTypeBool, true, true},
+		{"address.geo", DocumentFieldTypeObject, nil, true},
+		{"a.b", DocumentFieldTypeString, "literal", true},
+		{"address.zip", "", nil, false},
+		{"address.city.name", "", nil, false},
+		{"id.value", "", nil, false},
+		{"missing.path", "", nil, false},
+	}
+	for _, tt := range tests {
+		field, found := lookupField(doc, tt.path)
+		if found != tt.wantFound {
+			t.Fatalf("lookupField(%q): expected found=%v, got %v", tt.path, tt.wantFound, found)
+		}
+		if !found {
+			continue
+		}
+		if field.Type != tt.wantType {
+			t.Fatalf("lookupField(%q): expected type %q, got %q", tt.path, tt.wantType, field.Type)
+		}
+		if tt.wantValue != nil && field.Value != tt.wantValue {
+			t.Fatalf("lookupField(%q): expected value %v, got %v", tt.path, tt.wantValue, field.Value)
+		}
+	}
+}
+
+func TestNestedPathIndex(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(addressDoc("1", map[string]any{"city": "Lviv", "zip": 79000}))
+	col.Put(addressDoc("2", map[string]any{"city": "Kyiv"}))
+	col.Put(addressDoc("3", nil))
+	col.Put(addressDoc("4", map[string]DocumentField{
+		"city": {Type: DocumentFieldTypeString, Value: "Odesa"},
+	}))
+
+	// A document decoded from JSON holds nested values as map[string]any.
+	var decoded Document
+	json.Unmarshal([]byte(`{"Fields":{"id":{"Type":"string","Value":"5"},"address":{"Type":"object","Value":{"city":"Dnipro","zip":49000}}}}`), &decoded)
+	col.Put(&decoded)
+
+	if err := col.CreateIndex("address.city"); err != nil {
+		t.Fatalf("unexpected error on CreateIndex: %v", err)
+	}
+	assertIDs(t, queryIDs(t, col, "address.city", QueryParams{}), "5", "2", "1", "4")
+	assertIDs(t, queryIDs(t, col, "address.city", QueryParams{MinValue: str("K"), MaxValue: str("M")}), "2", "1")
+
+	col.CreateIndex("address.zip")
+	assertIDs(t, queryIDs(t, col, "address.zip", QueryParams{MinValue: number(50000)}), "1")
+
+	col.Put(addressDoc("1", map[string]any{"city": "Kharkiv"}))
+	assertIDs(t, queryIDs(t, col, "address.city", QueryParams{MaxValue: str("Kyiv")}), "5", "1", "2")
+	if got := queryIDs(t, col, "address.zip", QueryParams{}); len(got) != 1 || got[0] != "5" {
+		t.Fatalf("expected replaced document to leave the zip index, got %v", got)
+	}
+}
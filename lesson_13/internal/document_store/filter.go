@@ -0,0 +1,315 @@
+package document_store
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+type FilterOp string
+
+const (
+	FilterOpEq     FilterOp = "eq"
+	FilterOpNe     FilterOp = "ne"
+	FilterOpGt     FilterOp = "gt"
+	FilterOpGte    FilterOp = "gte"
+	FilterOpLt     FilterOp = "lt"
+	FilterOpLte    FilterOp = "lte"
+	FilterOpIn     FilterOp = "in"
+	FilterOpExists FilterOp = "exists"
+	FilterOpPrefix FilterOp = "prefix"
+	FilterOpAnd    FilterOp = "and"
+	FilterOpOr     FilterOp = "or"
+	FilterOpNot    FilterOp = "not"
+)
+
+// Filter is a predicate over documents. Comparison ops take Value, "in"
+// takes Values, "exists" takes an optional bool Value (true by default) and
+// "and", "or" and "not" combine Filters ("not" takes exactly one).
+// Field may be a dotted path. Values of different types never compare as
+// equal or ordered, and an array field matches when any of its elements does.
+type Filter struct {
+	Op      FilterOp
+	Field   string
+	Value   *DocumentField
+	Values  []DocumentField
+	Filters []Filter
+}
+
+func (f *Filter) validate() error {
+	switch f.Op {
+	case FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte:
+		if f.Field == "" || f.Value == nil {
+			return ErrInvalidFilter
+		}
+		if _, ok := encodeIndexValue(*f.Value); !ok {
+			return ErrInvalidFilter
+		}
+	case FilterOpPrefix:
+		if f.Field == "" || f.Value == nil || f.Value.Type != DocumentFieldTypeString {
+			return ErrInvalidFilter
+		}
+		if _, ok := f.Value.Value.(string); !ok {
+			return ErrInvalidFilter
+		}
+	case FilterOpIn:
+		if f.Field == "" {
+			return ErrInvalidFilter
+		}
+		for _, value := range f.Values {
+			if _, ok := encodeIndexValue(value); !ok {
+				return ErrInvalidFilter
+			}
+		}
+	case FilterOpExists:
+		if f.Field == "" {
+			return ErrInvalidFilter
+		}
+		if f.Value != nil {
+			if _, ok := f.Value.Value.(bool); !ok {
+				return ErrInvalidFilter
+			}
+		}
+	case FilterOpAnd, FilterOpOr:
+		for i := range f.Filters {
+			if err := f.Filters[i].validate(); err != nil {
+				return err
+			}
+		}
+	case FilterOpNot:
+		if len(f.Filters) != 1 {
+			return ErrInvalidFilter
+		}
+		return f.Filters[0].validate()
+	default:
+		return ErrInvalidFilter
+	}
+	return nil
+}
+
+// matches expects a validated filter.
+func (f *Filter) matches(doc *Document) bool {
+	switch f.Op {
+	case FilterOpAnd:
+		for i := range f.Filters {
+			if !f.Filters[i].matches(doc) {
+				return false
+			}
+		}
+		return true
+	case FilterOpOr:
+		for i := range f.Filters {
+			if f.Filters[i].matches(doc) {
+				return true
+			}
+		}
+		return false
+	case FilterOpNot:
+		return !f.Filters[0].matches(doc)
+	case FilterOpExists:
+		_, exists := lookupField(doc, f.Field)
+		want := true
+		if f.Value != nil {
+			want = f.Value.Value.(bool)
+		}
+		return exists == want
+	case FilterOpNe:
+		eq := Filter{Op: FilterOpEq, Field: f.Field, Value: f.Value}
+		return !eq.matches(doc)
+	}
+
+	field, exists := lookupField(doc, f.Field)
+	if !exists {
+		return false
+	}
+	candidates := []DocumentField{field}
+	if field.Type == DocumentFieldTypeArray {
+		for _, element := range arrayElements(field.Value) {
+			if elementField, ok := fieldFromValue(element); ok {
+				candidates = append(candidates, elementField)
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		if f.matchesValue(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) matchesValue(field DocumentField) bool {
+	value, ok := encodeIndexValue(field)
+	if !ok {
+		return false
+	}
+	switch f.Op {
+	case FilterOpIn:
+		for _, v := range f.Values {
+			if target, _ := encodeIndexValue(v); target == value {
+				return true
+			}
+		}
+		return false
+	case FilterOpPrefix:
+		s, ok := field.Value.(string)
+		return ok && field.Type == DocumentFieldTypeString && strings.HasPrefix(s, f.Value.Value.(string))
+	}
+
+	target, _ := encodeIndexValue(*f.Value)
+	if value[0] != target[0] {
+		return false
+	}
+	switch f.Op {
+	case FilterOpEq:
+		return value == target
+	case FilterOpGt:
+		return value > target
+	case FilterOpGte:
+		return value >= target
+	case FilterOpLt:
+		return value < target
+	case FilterOpLte:
+		return value <= target
+	}
+	return false
+}
+
+// keyRanges returns the index key ranges that hold every document a leaf
+// filter can match, or false if the filter cannot be answered from an index.
+func (f *Filter) keyRanges() ([][2]string, bool) {
+	switch f.Op {
+	case FilterOpEq:
+		value, _ := encodeIndexValue(*f.Value)
+		return [][2]string{{value, value + "\xff"}}, true
+	case FilterOpGt, FilterOpGte:
+		value, _ := encodeIndexValue(*f.Value)
+		return [][2]string{{value, string([]byte{value[0] + 1})}}, true
+	case FilterOpLt, FilterOpLte:
+		value, _ := encodeIndexValue(*f.Value)
+		return [][2]string{{value[:1], value + "\xff"}}, true
+	case FilterOpIn:
+		ranges := make([][2]string, 0, len(f.Values))
+		for _, v := range f.Values {
+			value, _ := encodeIndexValue(v)
+			ranges = append(ranges, [2]string{value, value + "\xff"})
+		}
+		return ranges, true
+	case FilterOpPrefix:
+		value := encodeString(f.Value.Value.(string))
+		value = value[:len(value)-2] // without the terminator
+		return [][2]string{{value, value + "\xff"}}, true
+	}
+	return nil, false
+}
+
+// candidates returns a superset of the documents matching f found through
+// the indexes, or false if a full scan is needed. Must be called with the
+// read lock held.
+func (c *CollectionImpl) candidates(f *Filter) ([]*Document, bool) {
+	switch f.Op {
+	case FilterOpAnd:
+		var best []*Document
+		found := false
+		for i := range f.Filters {
+			if docs, ok := c.candidates(&f.Filters[i]); ok && (!found || len(docs) < len(best)) {
+				best, found = docs, true
+			}
+		}
+		return best, found
+	case FilterOpOr:
+		var union []*Document
+		seen := make(map[*Document]bool)
+		for i := range f.Filters {
+			docs, ok := c.candidates(&f.Filters[i])
+			if !ok {
+				return nil, false
+			}
+			for _, doc := range docs {
+				if !seen[doc] {
+					seen[doc] = true
+					union = append(union, doc)
+				}
+			}
+		}
+		return union, true
+	}
+
+	idx := c.indexFor(f.Field)
+	if idx == nil {
+		return nil, false
+	}
+	ranges, ok := f.keyRanges()
+	if !ok {
+		return nil, false
+	}
+	var docs []*Document
+	seen := make(map[*Document]bool)
+	for _, r := range ranges {
+		startIdx, endIdx := idx.span(r[0], r[1])
+		for _, key := range idx.keys[startIdx:endIdx] {
+			for _, doc := range idx.docs(key) {
+				if !seen[doc] {
+					seen[doc] = true
+					docs = append(docs, doc)
+				}
+			}
+		}
+	}
+	return docs, true
+}
+
+// indexFor returns a single-field index on fieldName that holds every
+// document with a scalar value there. Documents with an array value are only
+// indexed by multikey indexes.
+func (c *CollectionImpl) indexFor(fieldName string) *index {
+	for _, idx := range c.indexes {
+		if len(idx.options.Fields) != 1 || idx.options.Fields[0] != fieldName {
+			continue
+		}
+		if idx.options.Multikey || idx.arrays == 0 {
+			return idx
+		}
+	}
+	return nil
+}
+
+// matching returns the documents matching a validated filter in no
+// particular order. Must be called with the read lock held.
+func (c *CollectionImpl) matching(filter *Filter) []*Document {
+	docs, ok := c.candidates(filter)
+	if !ok {
+		docs = make([]*Document, 0, len(c.documents))
+		for _, doc := range c.documents {
+			docs = append(docs, doc)
+		}
+	}
+
+	var result []*Document
+	for _, doc := range docs {
+		if filter.matches(doc) && !c.expired(doc) {
+			result = append(result, doc)
+		}
+	}
+	return result
+}
+
+// Find returns the documents matching filter ordered by primary key.
+// It reads only the documents an index selects when one covers the filter.
+func (c *CollectionImpl) Find(filter Filter) ([]Document, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	docs := c.matching(&filter)
+	sort.Slice(docs, func(i, j int) bool { return docs[i].order < docs[j].order })
+	var result []Document
+	for _, doc := range docs {
+		result = append(result, *cloneDocument(doc))
+	}
+	return result, nil
+}
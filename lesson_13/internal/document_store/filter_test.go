@@ -0,0 +1,166 @@
+package document_store
+
+import (
+	"testing"
+)
+
+func findIDs(t *testing.T, col *CollectionImpl, filter Filter) []string {
+	t.Helper()
+	docs, err := col.Find(filter)
+	if err != nil {
+		t.Fatalf("unexpected error on Find: %v", err)
+	}
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.Fields["id"].Value.(string))
+	}
+	return ids
+}
+
+func newProductsCollection(t *testing.T) *CollectionImpl {
+	t.Helper()
+	col, err := NewStore().CreateCollection("products", &CollectionConfig{PrimaryKey: "id"})
+	if err != nil {
+		t.Fatalf("unexpected error creating collection: %v", err)
+	}
+	products := []struct {
+		id    string
+		name  string
+		price float64
+		stock bool
+		tags  []any
+	}{
+		{"1", "apple", 1.5, true, []any{"fruit", "red"}},
+		{"2", "apricot", 3, false, []any{"fruit"}},
+		{"3", "banana", 0.5, true, []any{"fruit", "yellow"}},
+		{"4", "bread", 2.5, true, nil},
+		{"5", "butter", 4, false, []any{"dairy"}},
+	}
+	for _, p := range products {
+		doc := &Document{Fields: map[string]DocumentField{
+			"id":    {Type: DocumentFieldTypeString, Value: p.id},
+			"name":  {Type: DocumentFieldTypeString, Value: p.name},
+			"price": {Type: DocumentFieldTypeNumber, Value: p.price},
+			"stock": {Type: DocumentFieldTypeBool, Value: p.stock},
+		}}
+		if p.tags != nil {
+			doc.Fields["tags"] = DocumentField{Type: DocumentFieldTypeArray, Value: p.tags}
+		}
+		col.Put(doc)
+	}
+	return col
+}
+
+var filterCases = []struct {
+	name   string
+	filter Filter
+	want   []string
+}{
+	{"eq", Filter{Op: FilterOpEq, Field: "name", Value: str("bread")}, []string{"4"}},
+	{"ne", Filter{Op: FilterOpNe, Field: "stock", Value: boolean(true)}, []string{"2", "5"}},
+	{"gt", Filter{Op: FilterOpGt, Field: "price", Value: number(2.5)}, []string{"2", "5"}},
+	{"gte", Filter{Op: FilterOpGte, Field: "price", Value: number(2.5)}, []string{"2", "4", "5"}},
+	{"lt", Filter{Op: FilterOpLt, Field: "price", Value: number(1.5)}, []string{"3"}},
+	{"lte", Filter{Op: FilterOpLte, Field: "price", Value: number(1.5)}, []string{"1", "3"}},
+	{"gt other type", Filter{Op: FilterOpGt, Field: "price", Value: str("")}, nil},
+	{"in", Filter{Op: FilterOpIn, Field: "name", Values: []DocumentField{*str("apple"), *str("butter"), *str("kiwi")}}, []string{"1", "5"}},
+	{"exists", Filter{Op: FilterOpExists, Field: "tags"}, []string{"1", "2", "3", "5"}},
+	{"not exists", Filter{Op: FilterOpExists, Field: "tags", Value: boolean(false)}, []string{"4"}},
+	{"prefix", Filter{Op: FilterOpPrefix, Field: "name", Value: str("ap")}, []string{"1", "2"}},
+	{"array element", Filter{Op: FilterOpEq, Field: "tags", Value: str("fruit")}, []string{"1", "2", "3"}},
+	{"and", Filter{Op: FilterOpAnd, Filters: []Filter{
+		{Op: FilterOpPrefix, Field: "name", Value: str("b")},
+		{Op: FilterOpEq, Field: "stock", Value: boolean(true)},
+	}}, []string{"3", "4"}},
+	{"or", Filter{Op: FilterOpOr, Filters: []Filter{
+		{Op: FilterOpLt, Field: "price", Value: number(1)},
+		{Op: FilterOpEq, Field: "tags", Value: str("dairy")},
+	}}, []string{"3", "5"}},
+	{"not", Filter{Op: FilterOpNot, Filters: []Filter{
+		{Op: FilterOpEq, Field: "tags", Value: str("fruit")},
+	}}, []string{"4", "5"}},
+	{"empty and", Filter{Op: FilterOpAnd}, []string{"1", "2", "3", "4", "5"}},
+}
+
+func TestFind_FullScan(t *testing.T) {
+	col := newProductsCollection(t)
+	for _, tc := range filterCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertIDs(t, findIDs(t, col, tc.filter), tc.want...)
+		})
+	}
+}
+
+func TestFind_WithIndexes(t *testing.T) {
+	col := newProductsCollection(t)
+	col.CreateIndex("name")
+	col.CreateIndex("price")
+	col.CreateIndex("stock")
+	col.CreateIndex("tags", IndexOptions{Multikey: true})
+
+	for _, tc := range filterCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertIDs(t, findIDs(t, col, tc.filter), tc.want...)
+		})
+	}
+}
+
+func TestFind_UsesIndex(t *testing.T) {
+	col := newProductsCollection(t)
+	col.CreateIndex("price")
+
+	filter := Filter{Op: FilterOpAnd, Filters: []Filter{
+		{Op: FilterOpGte, Field: "price", Value: number(3)},
+		{Op: FilterOpExists, Field: "tags"},
+	}}
+	docs, ok := col.candidates(&filter)
+	if !ok {
+		t.Fatal("expected the price index to be used")
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 candidates from the index, got %d", len(docs))
+	}
+
+	notCovered := Filter{Op: FilterOpOr, Filters: []Filter{
+		{Op: FilterOpGte, Field: "price", Value: number(3)},
+		{Op: FilterOpEq, Field: "name", Value: str("apple")},
+	}}
+	if _, ok := col.candidates(&notCovered); ok {
+		t.Fatal("expected a full scan when one branch of or has no index")
+	}
+}
+
+func TestFind_IgnoresIndexSkippingArrays(t *testing.T) {
+	col := newProductsCollection(t)
+	col.CreateIndex("tags")
+
+	if idx := col.indexFor("tags"); idx != nil {
+		t.Fatal("expected a non-multikey index over arrays not to be used")
+	}
+	assertIDs(t, findIDs(t, col, Filter{Op: FilterOpEq, Field: "tags", Value: str("fruit")}), "1", "2", "3")
+
+	col.Delete("1")
+	col.Delete("2")
+	col.Delete("3")
+	col.Delete("5")
+	if idx := col.indexFor("tags"); idx == nil {
+		t.Fatal("expected the index to be usable once no document holds an array")
+	}
+}
+
+func TestFind_InvalidFilter(t *testing.T) {
+	col := newProductsCollection(t)
+	invalid := []Filter{
+		{Op: "regex", Field: "name", Value: str("a")},
+		{Op: FilterOpEq, Field: "name"},
+		{Op: FilterOpGt, Value: number(1)},
+		{Op: FilterOpPrefix, Field: "name", Value: number(1)},
+		{Op: FilterOpNot},
+		{Op: FilterOpAnd, Filters: []Filter{{Op: FilterOpEq}}},
+	}
+	for _, filter := range invalid {
+		if _, err := col.Find(filter); err != ErrInvalidFilter {
+			t.Fatalf("expected ErrInvalidFilter for %+v, got %v", filter, err)
+		}
+	}
+}
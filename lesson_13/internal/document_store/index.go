@@ -0,0 +1,315 @@
+package document_store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+var ErrUniqueConstraintViolation = errors.New("unique constraint violation")
+
+// UniqueConstraintError lists the primary keys of documents that share a
+// value of a unique index, one group per shared value.
+type UniqueConstraintError struct {
+	Index     string
+	Conflicts [][]string
+}
+
+func (e *UniqueConstraintError) Error() string {
+	groups := make([]string, 0, len(e.Conflicts))
+	for _, keys := range e.Conflicts {
+		groups = append(groups, "["+strings.Join(keys, ", ")+"]")
+	}
+	return fmt.Sprintf("%v: index %q: conflicting keys %s", ErrUniqueConstraintViolation, e.Index, strings.Join(groups, " "))
+}
+
+func (e *UniqueConstraintError) Unwrap() error {
+	return ErrUniqueConstraintViolation
+}
+
+// IndexOptions describe an index. Fields may be dotted paths into nested
+// objects, such as "address.city". A compound index lists several Fields and
+// orders documents by the tuple of their values; documents missing any of
+// the fields are left out of the index.
+// A Unique index rejects a document whose indexed value is already held by a
+// document with a different primary key.
+// A Multikey index indexes every element of array fields, so a range query
+// matches documents whose array holds at least one value in the range.
+type IndexOptions struct {
+	Fields   []string `json:"fields"`
+	Unique   bool     `json:"unique,omitempty"`
+	Multikey bool     `json:"multikey,omitempty"`
+}
+
+type index struct {
+	options IndexOptions
+	// data maps each key to its documents in primary key order.
+	data map[string]*docList
+	keys []string
+	// arrays counts documents left out of a non-multikey index because one
+	// of the indexed fields holds an array.
+	arrays int
+	// During a batch keys is only brought up to date by endBatch: added keys
+	// wait in pending and removed ones stay until then. The same goes for the
+	// order of the documents in the lists collected in unsorted.
+	batching bool
+	pending  []string
+	unsorted []*docList
+}
+
+func newIndex(options IndexOptions) *index {
+	return &index{
+		options: options,
+		data:    make(map[string]*docList),
+		keys:    make([]string, 0),
+	}
+}
+
+// keysFor concatenates the encoded values of the indexed fields. The encoding
+// of every value is self-delimiting, so the concatenation sorts as a tuple.
+// A multikey index gets one key per array element (per combination of
+// elements when several fields are arrays); the keys are deduplicated.
+func (idx *index) keysFor(doc *Document) []string {
+	keys := []string{""}
+	for _, fieldName := range idx.options.Fields {
+		field, exists := lookupField(doc, fieldName)
+		if !exists {
+			return nil
+		}
+		values := idx.encodeField(field)
+		if len(values) == 0 {
+			return nil
+		}
+		next := make([]string, 0, len(keys)*len(values))
+		for _, key := range keys {
+			for _, value := range values {
+				next = append(next, key+value)
+			}
+		}
+		keys = next
+	}
+	if len(keys) > 1 {
+		sort.Strings(keys)
+		keys = slices.Compact(keys)
+	}
+	return keys
+}
+
+func (idx *index) encodeField(field DocumentField) []string {
+	if field.Type != DocumentFieldTypeArray || !idx.options.Multikey {
+		if value, ok := encodeIndexValue(field); ok {
+			return []string{value}
+		}
+		return nil
+	}
+	var values []string
+	for _, element := range arrayElements(field.Value) {
+		if elementField, ok := fieldFromValue(element); ok {
+			if value, ok := encodeIndexValue(elementField); ok {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func (idx *index) skipsArray(doc *Document) bool {
+	if idx.options.Multikey {
+		return false
+	}
+	for _, fieldName := range idx.options.Fields {
+		if field, exists := lookupField(doc, fieldName); exists && field.Type == DocumentFieldTypeArray {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *index) add(doc *Document) {
+	if idx.skipsArray(doc) {
+		idx.arrays++
+	}
+	for _, value := range idx.keysFor(doc) {
+		list, exists := idx.data[value]
+		if !exists {
+			list = &docList{}
+			idx.data[value] = list
+			if idx.batching {
+				idx.pending = append(idx.pending, value)
+			} else {
+				insertPos := sort.SearchStrings(idx.keys, value)
+				idx.keys = append(idx.keys, "")
+				copy(idx.keys[insertPos+1:], idx.keys[insertPos:])
+				idx.keys[insertPos] = value
+			}
+		}
+		if list.add(doc, idx.batching) {
+			idx.unsorted = append(idx.unsorted, list)
+		}
+	}
+}
+
+func (idx *index) remove(doc *Document) {
+	if idx.skipsArray(doc) {
+		idx.arrays--
+	}
+	for _, value := range idx.keysFor(doc) {
+		list, exists := idx.data[value]
+		if !exists {
+			continue
+		}
+		list.remove(doc)
+		if len(list.docs) > 0 {
+			continue
+		}
+		delete(idx.data, value)
+		if idx.batching {
+			continue
+		}
+		keyPos := sort.SearchStrings(idx.keys, value)
+		if keyPos < len(idx.keys) && idx.keys[keyPos] == value {
+			copy(idx.keys[keyPos:], idx.keys[keyPos+1:])
+			idx.keys = idx.keys[:len(idx.keys)-1]
+		}
+	}
+}
+
+// docs returns the documents stored under value in primary key order.
+func (idx *index) docs(value string) []*Document {
+	if list, exists := idx.data[value]; exists {
+		return list.docs
+	}
+	return nil
+}
+
+func (idx *index) beginBatch() {
+	idx.batching = true
+}
+
+// endBatch merges the keys added during the batch into the sorted keys and
+// drops the removed ones, in a single pass, then sorts the document lists
+// that went out of order.
+func (idx *index) endBatch() {
+	idx.batching = false
+	for _, list := range idx.unsorted {
+		list.sort()
+	}
+	idx.unsorted = nil
+	sort.Strings(idx.pending)
+	keys := make([]string, 0, len(idx.data))
+	i, j := 0, 0
+	for i < len(idx.keys) || j < len(idx.pending) {
+		var key string
+		if j == len(idx.pending) || i < len(idx.keys) && idx.keys[i] <= idx.pending[j] {
+			key = idx.keys[i]
+			i++
+		} else {
+			key = idx.pending[j]
+			j++
+		}
+		if _, exists := idx.data[key]; exists && (len(keys) == 0 || keys[len(keys)-1] != key) {
+			keys = append(keys, key)
+		}
+	}
+	idx.keys = keys
+	idx.pending = nil
+}
+
+// bounds returns the half-open range of idx.keys matched by params.
+func (idx *index) bounds(params QueryParams) (int, int, error) {
+	if len(params.Prefix) > len(idx.options.Fields) ||
+		len(params.Prefix) == len(idx.options.Fields) && (params.MinValue != nil || params.MaxValue != nil) {
+		return 0, 0, ErrInvalidQuery
+	}
+
+	var prefix, min, max string
+	for i, field := range params.Prefix {
+		value, ok := encodeIndexValue(field)
+		if !ok {
+			return 0, 0, boundError("prefix["+strconv.Itoa(i)+"]", field)
+		}
+		prefix += value
+	}
+	var ok bool
+	if params.MinValue != nil {
+		if min, ok = encodeIndexValue(*params.MinValue); !ok {
+			return 0, 0, boundError("min_value", *params.MinValue)
+		}
+	}
+	if params.MaxValue != nil {
+		if max, ok = encodeIndexValue(*params.MaxValue); !ok {
+			return 0, 0, boundError("max_value", *params.MaxValue)
+		}
+	}
+
+	// No encoded value starts with 0xff, so appending it to a key prefix
+	// gives an upper bound for every key that starts with that prefix.
+	lower, upper := prefix, prefix+"\xff"
+	switch {
+	case params.MinValue != nil && params.MaxValue != nil:
+		lower, upper = prefix+min, prefix+max+"\xff"
+	case params.MinValue != nil:
+		lower, upper = prefix+min, prefix+string([]byte{min[0] + 1})
+	case params.MaxValue != nil:
+		lower, upper = prefix+max[:1], prefix+max+"\xff"
+	}
+
+	startIdx, endIdx := idx.span(lower, upper)
+	return startIdx, endIdx, nil
+}
+
+// span returns the positions in idx.keys of the keys in [lower, upper).
+func (idx *index) span(lower, upper string) (int, int) {
+	startIdx := sort.SearchStrings(idx.keys, lower)
+	endIdx := sort.SearchStrings(idx.keys, upper)
+	if endIdx < startIdx {
+		endIdx = startIdx
+	}
+	return startIdx, endIdx
+}
+
+// checkUnique must be called with the write lock held, before doc is stored
+// under key.
+func (c *CollectionImpl) checkUnique(key string, doc *Document) error {
+	for name, idx := range c.indexes {
+		if !idx.options.Unique {
+			continue
+		}
+		for _, value := range idx.keysFor(doc) {
+			for _, other := range idx.docs(value) {
+				if otherKey, _ := c.documentKey(other); otherKey != key && !c.expired(other) {
+					return &UniqueConstraintError{Index: name, Conflicts: [][]string{{otherKey, key}}}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// uniqueConflicts lists the keys of the live documents sharing a value of
+// idx. Like checkUnique, it ignores expired documents.
+func (c *CollectionImpl) uniqueConflicts(name string, idx *index) error {
+	var conflicts [][]string
+	for _, value := range idx.keys {
+		var keys []string
+		for _, doc := range idx.docs(value) {
+			if !c.expired(doc) {
+				key, _ := c.documentKey(doc)
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) < 2 {
+			continue
+		}
+		sort.Strings(keys)
+		conflicts = append(conflicts, keys)
+	}
+	if len(conflicts) > 0 {
+		return &UniqueConstraintError{Index: name, Conflicts: conflicts}
+	}
+	return nil
+}
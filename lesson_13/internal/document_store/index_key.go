@@ -0,0 +1,118 @@
+package document_store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+// Index keys are strings whose byte order matches the order of the values
+// they encode, so an index can keep them in a plain sorted []string.
+// Every key starts with a type tag, which orders bool < number < string.
+const (
+	indexTagBool   byte = 'b'
+	indexTagNumber byte = 'n'
+	indexTagString byte = 's'
+)
+
+// encodeIndexValue returns the index key for a field, or false if the field
+// type cannot be indexed.
+func encodeIndexValue(field DocumentField) (string, bool) {
+	switch field.Type {
+	case DocumentFieldTypeBool:
+		value, ok := field.Value.(bool)
+		if !ok {
+			return "", false
+		}
+		if value {
+			return string([]byte{indexTagBool, 1}), true
+		}
+		return string([]byte{indexTagBool, 0}), true
+	case DocumentFieldTypeNumber:
+		value, ok := numberValue(field.Value)
+		if !ok || math.IsNaN(value) {
+			return "", false
+		}
+		return encodeNumber(value), true
+	case DocumentFieldTypeString:
+		value, ok := field.Value.(string)
+		if !ok {
+			return "", false
+		}
+		return encodeString(value), true
+	default:
+		return "", false
+	}
+}
+
+// encodeNumber maps a float64 onto 8 big-endian bytes that sort like the
+// numbers themselves: negative values have all bits flipped, the rest only
+// the sign bit.
+func encodeNumber(value float64) string {
+	if value == 0 {
+		value = 0 // normalizes -0
+	}
+	bits := math.Float64bits(value)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	buf := make([]byte, 9)
+	buf[0] = indexTagNumber
+	binary.BigEndian.PutUint64(buf[1:], bits)
+	return string(buf)
+}
+
+// encodeString terminates the value with "\x00\x01" and escapes zero bytes
+// as "\x00\xff", so a string sorts before any longer string it prefixes.
+func encodeString(value string) string {
+	var b strings.Builder
+	b.Grow(len(value) + 3)
+	b.WriteByte(indexTagString)
+	for i := 0; i < len(value); i++ {
+		b.WriteByte(value[i])
+		if value[i] == 0 {
+			b.WriteByte(0xff)
+		}
+	}
+	b.WriteString("\x00\x01")
+	return b.String()
+}
+
+// numberValue accepts the numeric types Go callers use as well as the
+// float64 and json.Number values produced by JSON decoding.
+func numberValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,265 @@
+package document_store
+
+import (
+	"errors"
+	"testing"
+)
+
+func number(v float64) *DocumentField {
+	return &DocumentField{Type: DocumentFieldTypeNumber, Value: v}
+}
+
+func str(v string) *DocumentField {
+	return &DocumentField{Type: DocumentFieldTypeString, Value: v}
+}
+
+func boolean(v bool) *DocumentField {
+	return &DocumentField{Type: DocumentFieldTypeBool, Value: v}
+}
+
+func queryIDs(t *testing.T, col *CollectionImpl, fieldName string, params QueryParams) []string {
+	t.Helper()
+	docs, err := col.Query(fieldName, params)
+	if err != nil {
+		t.Fatalf("unexpected error on Query(%q): %v", fieldName, err)
+	}
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.Fields["id"].Value.(string))
+	}
+	return ids
+}
+
+func assertIDs(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected ids %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected ids %v, got %v", want, got)
+		}
+	}
+}
+
+func newPeopleCollection(t *testing.T) *CollectionImpl {
+	t.Helper()
+	col, err := NewStore().CreateCollection("people", &CollectionConfig{PrimaryKey: "id"})
+	if err != nil {
+		t.Fatalf("unexpected error creating collection: %v", err)
+	}
+	people := []struct {
+		id     string
+		age    any
+		active bool
+	}{
+		{"1", 25, true},
+		{"2", 9, false},
+		{"3", 100.5, true},
+		{"4", -3, false},
+		{"5", int64(30), true},
+	}
+	for _, p := range people {
+		_, err := col.Put(&Document{Fields: map[string]DocumentField{
+			"id":     {Type: DocumentFieldTypeString, Value: p.id},
+			"age":    {Type: DocumentFieldTypeNumber, Value: p.age},
+			"active": {Type: DocumentFieldTypeBool, Value: p.active},
+		}})
+		if err != nil {
+			t.Fatalf("unexpected error on Put: %v", err)
+		}
+	}
+	return col
+}
+
+func TestQuery_NumberIndexOrdersNumerically(t *testing.T) {
+	col := newPeopleCollection(t)
+	if err := col.CreateIndex("age"); err != nil {
+		t.Fatalf("unexpected error on CreateIndex: %v", err)
+	}
+
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{}), "4", "2", "1", "5", "3")
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{Desc: true}), "3", "5", "1", "2", "4")
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{MinValue: number(9), MaxValue: number(30)}), "2", "1", "5")
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{MinValue: number(26)}), "5", "3")
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{MaxValue: number(0)}), "4")
+}
+
+func TestQuery_BoolIndex(t *testing.T) {
+	col := newPeopleCollection(t)
+	if err := col.CreateIndex("active"); err != nil {
+		t.Fatalf("unexpected error on CreateIndex: %v", err)
+	}
+
+	docs, _ := col.Query("active", QueryParams{})
+	if len(docs) != 5 || docs[0].Fields["active"].Value != false || docs[4].Fields["active"].Value != true {
+		t.Fatalf("expected false before true, got %+v", docs)
+	}
+	got := queryIDs(t, col, "active", QueryParams{MinValue: boolean(true), MaxValue: boolean(true)})
+	if len(got) != 3 {
+		t.Fatalf("expected 3 active people, got %v", got)
+	}
+}
+
+func TestQuery_SingleBoundMatchesOnlyItsType(t *testing.T) {
+	col := newPeopleCollection(t)
+	col.Put(&Document{Fields: map[string]DocumentField{
+		"id":  {Type: DocumentFieldTypeString, Value: "6"},
+		"age": {Type: DocumentFieldTypeString, Value: "unknown"},
+	}})
+	col.CreateIndex("age")
+
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{MinValue: number(30)}), "5", "3")
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{MinValue: str("")}), "6")
+	if got := queryIDs(t, col, "age", QueryParams{}); len(got) != 6 || got[5] != "6" {
+		t.Fatalf("expected numbers before strings, got %v", got)
+	}
+}
+
+func TestQuery_IndexFollowsUpdates(t *testing.T) {
+	col := newPeopleCollection(t)
+	col.CreateIndex("age")
+
+	col.Put(&Document{Fields: map[string]DocumentField{
+		"id":  {Type: DocumentFieldTypeString, Value: "4"},
+		"age": {Type: DocumentFieldTypeNumber, Value: 50},
+	}})
+	col.Delete("3")
+
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{}), "2", "1", "5", "4")
+}
+
+func TestQuery_StringIndexOrdersPrefixesFirst(t *testing.T) {
+	col, _ := NewStore().CreateCollection("words", &CollectionConfig{PrimaryKey: "id"})
+	for _, w := range []string{"ab", "a", "a\x00b", "b", ""} {
+		col.Put(&Document{Fields: map[string]DocumentField{
+			"id":   {Type: DocumentFieldTypeString, Value: w},
+			"word": {Type: DocumentFieldTypeString, Value: w},
+		}})
+	}
+	col.CreateIndex("word")
+
+	assertIDs(t, queryIDs(t, col, "word", QueryParams{}), "", "a", "a\x00b", "ab", "b")
+	assertIDs(t, queryIDs(t, col, "word", QueryParams{MinValue: str("a"), MaxValue: str("ab")}), "a", "a\x00b", "ab")
+}
+
+func TestQuery_InvalidBound(t *testing.T) {
+	col := newPeopleCollection(t)
+	col.CreateIndex("age")
+
+	_, err := col.Query("age", QueryParams{MinValue: &DocumentField{Type: DocumentFieldTypeNumber, Value: "x"}})
+	if !errors.Is(err, ErrUnsupportedDocumentField) {
+		t.Fatalf("expected ErrUnsupportedDocumentField, got %v", err)
+	}
+}
+
+func TestIndex_RebuiltFromDump(t *testing.T) {
+	col := newPeopleCollection(t)
+	col.CreateIndex("age")
+	store := col.store
+
+	dump, err := store.Dump()
+	if err != nil {
+		t.Fatalf("unexpected error on Dump: %v", err)
+	}
+	restored, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error on NewStoreFromDump: %v", err)
+	}
+	restoredCol, _ := restored.GetCollection("people")
+
+	assertIDs(t, queryIDs(t, restoredCol, "age", QueryParams{MinValue: number(9), MaxValue: number(30)}), "2", "1", "5")
+}
+
+func newEventsCollection(t *testing.T) *CollectionImpl {
+	t.Helper()
+	col, err := NewStore().CreateCollection("events", &CollectionConfig{PrimaryKey: "id"})
+	if err != nil {
+		t.Fatalf("unexpected error creating collection: %v", err)
+	}
+	events := []struct {
+		id        string
+		tenant    string
+		createdAt float64
+	}{
+		{"1", "acme", 300},
+		{"2", "globex", 100},
+		{"3", "acme", 100},
+		{"4", "acme", 200},
+		{"5", "ac", 150},
+	}
+	for _, e := range events {
+		col.Put(&Document{Fields: map[string]DocumentField{
+			"id":         {Type: DocumentFieldTypeString, Value: e.id},
+			"tenant":     {Type: DocumentFieldTypeString, Value: e.tenant},
+			"created_at": {Type: DocumentFieldTypeNumber, Value: e.createdAt},
+		}})
+	}
+	col.Put(&Document{Fields: map[string]DocumentField{
+		"id":     {Type: DocumentFieldTypeString, Value: "6"},
+		"tenant": {Type: DocumentFieldTypeString, Value: "acme"},
+	}})
+	return col
+}
+
+func TestCompoundIndex_PrefixAndRange(t *testing.T) {
+	col := newEventsCollection(t)
+	err := col.CreateIndex("tenant_created", IndexOptions{Fields: []string{"tenant", "created_at"}})
+	if err != nil {
+		t.Fatalf("unexpected error on CreateIndex: %v", err)
+	}
+
+	// Documents missing created_at are not indexed.
+	assertIDs(t, queryIDs(t, col, "tenant_created", QueryParams{}), "5", "3", "4", "1", "2")
+
+	acme := []DocumentField{*str("acme")}
+	assertIDs(t, queryIDs(t, col, "tenant_created", QueryParams{Prefix: acme}), "3", "4", "1")
+	assertIDs(t, queryIDs(t, col, "tenant_created", QueryParams{Prefix: acme, Desc: true}), "1", "4", "3")
+	assertIDs(t, queryIDs(t, col, "tenant_created", QueryParams{Prefix: acme, MinValue: number(150), MaxValue: number(300)}), "4", "1")
+	assertIDs(t, queryIDs(t, col, "tenant_created", QueryParams{Prefix: acme, MinValue: number(200)}), "4", "1")
+	assertIDs(t, queryIDs(t, col, "tenant_created", QueryParams{Prefix: acme, MaxValue: number(200)}), "3", "4")
+	assertIDs(t, queryIDs(t, col, "tenant_created", QueryParams{MinValue: str("acme"), MaxValue: str("acme")}), "3", "4", "1")
+
+	exact := []DocumentField{*str("acme"), *number(200)}
+	assertIDs(t, queryIDs(t, col, "tenant_created", QueryParams{Prefix: exact}), "4")
+
+	if _, err := col.Query("tenant_created", QueryParams{Prefix: exact, MinValue: number(1)}); err != ErrInvalidQuery {
+		t.Fatalf("expected ErrInvalidQuery, got %v", err)
+	}
+}
+
+func TestCompoundIndex_RoundTripsThroughDump(t *testing.T) {
+	col := newEventsCollection(t)
+	col.CreateIndex("tenant_created", IndexOptions{Fields: []string{"tenant", "created_at"}})
+	col.CreateIndex("tenant")
+
+	dump, err := col.store.Dump()
+	if err != nil {
+		t.Fatalf("unexpected error on Dump: %v", err)
+	}
+	restored, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error on NewStoreFromDump: %v", err)
+	}
+	restoredCol, _ := restored.GetCollection("events")
+
+	acme := []DocumentField{*str("acme")}
+	assertIDs(t, queryIDs(t, restoredCol, "tenant_created", QueryParams{Prefix: acme}), "3", "4", "1")
+	if got := queryIDs(t, restoredCol, "tenant", QueryParams{}); len(got) != 6 {
+		t.Fatalf("expected single-field index to be restored, got %v", got)
+	}
+}
+
+func TestNewStoreFromDump_LegacyIndexNames(t *testing.T) {
+	dump := []byte(`{"collections":{"users":{"config":{"PrimaryKey":"id"},"documents":{
+		"1":{"Fields":{"id":{"Type":"string","Value":"1"},"name":{"Type":"string","Value":"Bob"}}},
+		"2":{"Fields":{"id":{"Type":"string","Value":"2"},"name":{"Type":"string","Value":"Alice"}}}
+	},"index_names":["name"]}}}`)
+
+	store, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error on NewStoreFromDump: %v", err)
+	}
+	col, _ := store.GetCollection("users")
+	assertIDs(t, queryIDs(t, col, "name", QueryParams{}), "2", "1")
+}
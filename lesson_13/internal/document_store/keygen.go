@@ -0,0 +1,44 @@
+package document_store
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// keyGenerator makes UUIDv7 keys: a millisecond timestamp followed by random
+// bits. Within a millisecond the random bits are incremented instead of
+// drawn again, so every key sorts after the ones made before it, even if the
+// clock goes back.
+type keyGenerator struct {
+	mu sync.Mutex
+	ms uint64
+	// hi and lo are the 12 and 62 random bits around the version and
+	// variant bits.
+	hi, lo uint64
+}
+
+func (g *keyGenerator) next(now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if ms := uint64(now.UnixMilli()); ms > g.ms {
+		var random [10]byte
+		rand.Read(random[:])
+		g.ms = ms
+		g.hi = uint64(binary.BigEndian.Uint16(random[:2])) & (1<<12 - 1)
+		g.lo = binary.BigEndian.Uint64(random[2:]) & (1<<62 - 1)
+	} else if g.lo++; g.lo == 1<<62 {
+		g.lo = 0
+		if g.hi++; g.hi == 1<<12 {
+			g.hi = 0
+			g.ms++
+		}
+	}
+
+	var u [16]byte
+	binary.BigEndian.PutUint64(u[0:8], g.ms<<16|0x7000|g.hi)
+	binary.BigEndian.PutUint64(u[8:16], 1<<63|g.lo)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
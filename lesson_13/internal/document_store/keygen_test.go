@@ -0,0 +1,64 @@
+package document_store
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+var uuidV7 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestPut_GeneratesKeys(t *testing.T) {
+	store := NewStore()
+	clock := newFakeClock()
+	store.SetClock(clock)
+	col, _ := store.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", GenerateKeys: true})
+
+	doc := &Document{Fields: map[string]DocumentField{"name": *str("login")}}
+	key, err := col.Put(doc)
+	if err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	if !uuidV7.MatchString(key) || doc.Fields["id"].Value != key {
+		t.Fatalf("expected a UUIDv7 key set on the document, got %q and %v", key, doc.Fields["id"])
+	}
+	if stored, err := col.Get(key); err != nil || stored.Fields["name"].Value != "login" {
+		t.Fatalf("expected the document under the generated key, got %v, %v", stored, err)
+	}
+	if key, _ := col.Put(userDoc("mine", "Alice")); key != "mine" {
+		t.Fatalf("expected an existing key to be kept, got %q", key)
+	}
+
+	tx := store.Begin()
+	txKey, err := tx.Put("events", &Document{Fields: map[string]DocumentField{}})
+	if err != nil || !uuidV7.MatchString(txKey) {
+		t.Fatalf("expected Tx.Put to generate a key, got %q, %v", txKey, err)
+	}
+	tx.Commit()
+	if !col.Exists(txKey) {
+		t.Fatalf("expected the committed document under %q", txKey)
+	}
+}
+
+func TestKeyGenerator_Ordered(t *testing.T) {
+	var g keyGenerator
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := ""
+	for i := range 1000 {
+		at := now
+		switch {
+		case i > 900:
+			at = now.Add(-time.Hour)
+		case i > 500:
+			at = now.Add(time.Millisecond)
+		}
+		key := g.next(at)
+		if !uuidV7.MatchString(key) || key <= last {
+			t.Fatalf("expected key %d to be a UUIDv7 after %q, got %q", i, last, key)
+		}
+		last = key
+	}
+	if got := g.next(now.Add(time.Hour))[:8]; got <= last[:8] {
+		t.Fatalf("expected a later time to lead the key, got %s after %s", got, last)
+	}
+}
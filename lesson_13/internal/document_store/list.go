@@ -0,0 +1,258 @@
+package document_store
+
+import (
+	"container/heap"
+	"slices"
+	"sort"
+)
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListParams order documents by Sort, ties broken by ascending primary key,
+// or by primary key alone when Sort is empty. Documents missing a sort field,
+// or holding an array or object there, come after all others.
+type ListParams struct {
+	PageParams
+	Sort       []SortField
+	Projection *Projection
+}
+
+func (c *CollectionImpl) List() []Document {
+	page, _ := c.ListPage(ListParams{})
+	return page.Docs
+}
+
+func (c *CollectionImpl) ListPage(params ListParams) (*Page, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	for _, field := range params.Sort {
+		if field.Field == "" {
+			return nil, ErrInvalidQuery
+		}
+	}
+	if err := params.Projection.validate(); err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(params.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil && len(after.Values) != len(params.Sort) {
+		return nil, ErrInvalidCursor
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pager := newPager(params.PageParams)
+	switch {
+	case len(params.Sort) == 0:
+		c.listByKey(pager, after)
+	case len(params.Sort) == 1 && c.sortIndexFor(params.Sort[0].Field) != nil:
+		c.listByIndex(pager, c.sortIndexFor(params.Sort[0].Field), params.Sort[0].Desc, after)
+	default:
+		c.listSorted(pager, params.Sort, after)
+	}
+	return c.project(pager.page(), params.Projection), nil
+}
+
+func (c *CollectionImpl) listByKey(pager *pager, after *cursor) {
+	docs := c.ordered.docs
+	if after != nil {
+		docs = resume(docs, after.ID, false)
+	}
+	for _, doc := range docs {
+		if c.expired(doc) {
+			continue
+		}
+		if !pager.add(doc, cursor{ID: doc.order}) {
+			return
+		}
+	}
+}
+
+type sortEntry struct {
+	doc    *Document
+	values []string
+	id     string
+}
+
+func (c *CollectionImpl) sortEntry(doc *Document, sortFields []SortField) sortEntry {
+	entry := sortEntry{doc: doc, values: make([]string, len(sortFields))}
+	entry.id = doc.order
+	for i, sortField := range sortFields {
+		entry.values[i] = sortValue(doc, sortField.Field)
+	}
+	return entry
+}
+
+// sortValue returns the encoded value of a field, or "" when the document
+// cannot be ordered by it.
+func sortValue(doc *Document, path string) string {
+	field, exists := lookupField(doc, path)
+	if !exists {
+		return ""
+	}
+	value, _ := encodeIndexValue(field)
+	return value
+}
+
+// compareSortEntries orders a before b with a negative result.
+func compareSortEntries(a, b sortEntry, sortFields []SortField) int {
+	for i, sortField := range sortFields {
+		av, bv := a.values[i], b.values[i]
+		switch {
+		case av == bv:
+			continue
+		case av == "":
+			return 1
+		case bv == "":
+			return -1
+		case (av < bv) != sortField.Desc:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case a.id < b.id:
+		return -1
+	case a.id > b.id:
+		return 1
+	}
+	return 0
+}
+
+// entryHeap keeps the entry that sorts last on top.
+type entryHeap struct {
+	entries    []sortEntry
+	sortFields []SortField
+}
+
+func (h *entryHeap) Len() int { return len(h.entries) }
+func (h *entryHeap) Less(i, j int) bool {
+	return compareSortEntries(h.entries[i], h.entries[j], h.sortFields) > 0
+}
+func (h *entryHeap) Swap(i, j int) { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *entryHeap) Push(x any)    { h.entries = append(h.entries, x.(sortEntry)) }
+func (h *entryHeap) Pop() any {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
+// listSorted orders the documents by sort fields no index covers. A limited
+// page can only use the first Skip+Limit+1 of them, so only those are kept,
+// in a heap, and sorted.
+func (c *CollectionImpl) listSorted(pager *pager, sortFields []SortField, after *cursor) {
+	var last sortEntry
+	if after != nil {
+		last = sortEntry{values: after.Values, id: after.ID}
+	}
+	var keep int
+	if pager.params.Limit > 0 {
+		keep = pager.params.Skip + pager.params.Limit + 1
+	}
+
+	h := &entryHeap{sortFields: sortFields}
+	for _, doc := range c.ordered.docs {
+		if c.expired(doc) {
+			continue
+		}
+		entry := c.sortEntry(doc, sortFields)
+		if after != nil && compareSortEntries(entry, last, sortFields) <= 0 {
+			continue
+		}
+		switch {
+		case keep == 0 || h.Len() < keep:
+			h.entries = append(h.entries, entry)
+			if h.Len() == keep {
+				heap.Init(h)
+			}
+		case compareSortEntries(entry, h.entries[0], sortFields) < 0:
+			h.entries[0] = entry
+			heap.Fix(h, 0)
+		}
+	}
+	entries := h.entries
+	slices.SortFunc(entries, func(a, b sortEntry) int {
+		return compareSortEntries(a, b, sortFields)
+	})
+
+	for _, entry := range entries {
+		if !pager.add(entry.doc, cursor{Values: entry.values, ID: entry.id}) {
+			return
+		}
+	}
+}
+
+// sortIndexFor returns an index whose key order is the order of fieldName.
+// Multikey indexes do not qualify, since they hold documents under their
+// array elements.
+func (c *CollectionImpl) sortIndexFor(fieldName string) *index {
+	for _, idx := range c.indexes {
+		if len(idx.options.Fields) == 1 && idx.options.Fields[0] == fieldName && !idx.options.Multikey {
+			return idx
+		}
+	}
+	return nil
+}
+
+// listByIndex walks the index in order and only scans the collection for the
+// documents the index leaves out, which sort last, if the page is not full.
+func (c *CollectionImpl) listByIndex(pager *pager, idx *index, desc bool, after *cursor) {
+	startIdx, endIdx := 0, len(idx.keys)
+	var afterValue string
+	if after != nil {
+		afterValue = after.Values[0]
+	}
+	if after != nil && afterValue == "" {
+		startIdx, endIdx = 0, 0
+	} else if after != nil {
+		pos := sort.SearchStrings(idx.keys, afterValue)
+		if !desc {
+			startIdx = pos
+		} else {
+			if pos < len(idx.keys) && idx.keys[pos] == afterValue {
+				pos++
+			}
+			endIdx = pos
+		}
+	}
+
+	for n := startIdx; n < endIdx; n++ {
+		i := n
+		if desc {
+			i = startIdx + endIdx - 1 - n
+		}
+		key := idx.keys[i]
+		docs := idx.docs(key)
+		if after != nil && key == afterValue {
+			docs = resume(docs, after.ID, false)
+		}
+		for _, doc := range docs {
+			if c.expired(doc) {
+				continue
+			}
+			if !pager.add(doc, cursor{Values: []string{key}, ID: doc.order}) {
+				return
+			}
+		}
+	}
+
+	docs := c.ordered.docs
+	if after != nil && afterValue == "" {
+		docs = resume(docs, after.ID, false)
+	}
+	for _, doc := range docs {
+		if c.expired(doc) || len(idx.keysFor(doc)) > 0 {
+			continue
+		}
+		if !pager.add(doc, cursor{Values: []string{""}, ID: doc.order}) {
+			return
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package document_store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func listIDs(t *testing.T, col *CollectionImpl, params ListParams) []string {
+	t.Helper()
+	page, err := col.ListPage(params)
+	if err != nil {
+		t.Fatalf("unexpected error on ListPage: %v", err)
+	}
+	return pageIDs(page)
+}
+
+func allListIDs(t *testing.T, col *CollectionImpl, params ListParams) []string {
+	t.Helper()
+	var all []string
+	for {
+		page, err := col.ListPage(params)
+		if err != nil {
+			t.Fatalf("unexpected error on ListPage: %v", err)
+		}
+		all = append(all, pageIDs(page)...)
+		if page.NextCursor == "" {
+			return all
+		}
+		params.Cursor = page.NextCursor
+	}
+}
+
+func TestList_OrderedByPrimaryKey(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	for _, id := range []string{"c", "a", "e", "b", "d"} {
+		col.Put(userDoc(id, id))
+	}
+
+	for i := 0; i < 5; i++ {
+		docs := col.List()
+		ids := make([]string, 0, len(docs))
+		for _, doc := range docs {
+			ids = append(ids, doc.Fields["id"].Value.(string))
+		}
+		assertIDs(t, ids, "a", "b", "c", "d", "e")
+	}
+}
+
+func TestListPage_SortByFields(t *testing.T) {
+	col := newProductsCollection(t)
+	col.Put(&Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "6"},
+		"name": {Type: DocumentFieldTypeString, Value: "cheese"},
+	}})
+
+	byPrice := ListParams{Sort: []SortField{{Field: "price"}}}
+	assertIDs(t, listIDs(t, col, byPrice), "3", "1", "4", "2", "5", "6")
+
+	byPriceDesc := ListParams{Sort: []SortField{{Field: "price", Desc: true}}}
+	assertIDs(t, listIDs(t, col, byPriceDesc), "5", "2", "4", "1", "3", "6")
+
+	byStockThenName := ListParams{Sort: []SortField{{Field: "stock", Desc: true}, {Field: "name"}}}
+	assertIDs(t, listIDs(t, col, byStockThenName), "1", "3", "4", "2", "5", "6")
+
+	// Arrays cannot be ordered and sort with the missing values.
+	byTags := ListParams{Sort: []SortField{{Field: "tags"}}}
+	assertIDs(t, listIDs(t, col, byTags), "1", "2", "3", "4", "5", "6")
+
+	for limit := 1; limit <= 7; limit++ {
+		paged := byStockThenName
+		paged.Limit = limit
+		assertIDs(t, allListIDs(t, col, paged), "1", "3", "4", "2", "5", "6")
+	}
+	skipped := byStockThenName
+	skipped.Limit, skipped.Skip = 2, 3
+	assertIDs(t, listIDs(t, col, skipped), "2", "5")
+}
+
+func TestListPage_KeyOrderAfterBatches(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	var docs []*Document
+	for _, id := range []string{"e", "b", "g", "a", "f", "c", "d"} {
+		docs = append(docs, userDoc(id, id))
+	}
+	col.PutMany(docs)
+	col.DeleteMany([]string{"f", "a"})
+	col.Put(userDoc("ab", "ab"))
+
+	params := ListParams{PageParams: PageParams{Limit: 2}}
+	assertIDs(t, allListIDs(t, col, params), "ab", "b", "c", "d", "e", "g")
+
+	dump, _ := store.Dump()
+	loaded, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error loading dump: %v", err)
+	}
+	restored, _ := loaded.GetCollection("users")
+	assertIDs(t, allListIDs(t, restored, params), "ab", "b", "c", "d", "e", "g")
+}
+
+func TestListPage_SortUsesIndex(t *testing.T) {
+	col := newProductsCollection(t)
+	col.Put(&Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "0"},
+		"name": {Type: DocumentFieldTypeString, Value: "cheese"},
+	}})
+	col.Put(&Document{Fields: map[string]DocumentField{
+		"id":    {Type: DocumentFieldTypeString, Value: "7"},
+		"price": {Type: DocumentFieldTypeNumber, Value: 4},
+	}})
+	col.CreateIndex("price")
+	if col.sortIndexFor("price") == nil {
+		t.Fatal("expected the price index to be used for sorting")
+	}
+
+	for _, desc := range []bool{false, true} {
+		for limit := 1; limit <= 8; limit++ {
+			params := ListParams{Sort: []SortField{{Field: "price", Desc: desc}}}
+			params.Limit = limit
+			want := []string{"3", "1", "4", "2", "5", "7", "0"}
+			if desc {
+				want = []string{"5", "7", "2", "4", "1", "3", "0"}
+			}
+			assertIDs(t, allListIDs(t, col, params), want...)
+		}
+	}
+}
+
+func TestListPage_SortCursorMismatch(t *testing.T) {
+	col := newProductsCollection(t)
+	page, _ := col.ListPage(ListParams{PageParams: PageParams{Limit: 1}})
+
+	params := ListParams{Sort: []SortField{{Field: "price"}}}
+	params.Cursor = page.NextCursor
+	if _, err := col.ListPage(params); err != ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := col.ListPage(ListParams{Sort: []SortField{{}}}); err != ErrInvalidQuery {
+		t.Fatalf("expected ErrInvalidQuery, got %v", err)
+	}
+}
+
+func BenchmarkListPage(b *testing.B) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	docs := make([]*Document, 100000)
+	for i := range docs {
+		docs[i] = userDoc(fmt.Sprint(i), fmt.Sprintf("name%d", (i*7919)%len(docs)))
+	}
+	col.PutMany(docs)
+
+	for _, sort := range [][]SortField{nil, {{Field: "name"}, {Field: "id"}}} {
+		page, _ := col.ListPage(ListParams{Sort: sort, PageParams: PageParams{Limit: 10}})
+		params := ListParams{Sort: sort, PageParams: PageParams{Limit: 10, Cursor: page.NextCursor}}
+		b.Run(fmt.Sprintf("SortFields=%d", len(sort)), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				col.ListPage(params)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package document_store
+
+import (
+	"errors"
+	"testing"
+)
+
+func taggedDoc(id string, tags any) *Document {
+	return &Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: id},
+		"tags": {Type: DocumentFieldTypeArray, Value: tags},
+	}}
+}
+
+func TestMultikeyIndex_QueryByElement(t *testing.T) {
+	col, _ := NewStore().CreateCollection("posts", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(taggedDoc("1", []any{"go", "db", "go"}))
+	col.Put(taggedDoc("2", []string{"rust"}))
+	col.Put(taggedDoc("3", []any{"db", "ops"}))
+	col.Put(taggedDoc("4", []any{}))
+
+	if err := col.CreateIndex("tags", IndexOptions{Multikey: true}); err != nil {
+		t.Fatalf("unexpected error on CreateIndex: %v", err)
+	}
+
+	assertIDs(t, queryIDs(t, col, "tags", QueryParams{MinValue: str("go"), MaxValue: str("go")}), "1")
+	// Documents sharing an index key come back in primary key order.
+	assertIDs(t, queryIDs(t, col, "tags", QueryParams{MinValue: str("db"), MaxValue: str("db")}), "1", "3")
+	// Document 1 matches under both "db" and "go" but is returned once.
+	assertIDs(t, queryIDs(t, col, "tags", QueryParams{}), "1", "3", "2")
+	assertIDs(t, queryIDs(t, col, "tags", QueryParams{MinValue: str("e"), MaxValue: str("p")}), "1", "3")
+}
+
+func TestMultikeyIndex_Maintenance(t *testing.T) {
+	col, _ := NewStore().CreateCollection("posts", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("tags", IndexOptions{Multikey: true})
+	col.Put(taggedDoc("1", []any{"go", "db"}))
+	col.Put(taggedDoc("2", []any{"db"}))
+
+	col.Put(taggedDoc("1", []any{"ops"}))
+	assertIDs(t, queryIDs(t, col, "tags", QueryParams{MinValue: str("go"), MaxValue: str("go")}))
+	assertIDs(t, queryIDs(t, col, "tags", QueryParams{MinValue: str("db"), MaxValue: str("db")}), "2")
+
+	col.Delete("2")
+	assertIDs(t, queryIDs(t, col, "tags", QueryParams{}), "1")
+
+	idx := col.indexes["tags"]
+	if len(idx.keys) != 1 || len(idx.data) != 1 {
+		t.Fatalf("expected stale keys to be removed, got keys %q", idx.keys)
+	}
+}
+
+func TestMultikeyIndex_NumbersAndUnique(t *testing.T) {
+	col, _ := NewStore().CreateCollection("lots", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("tags", IndexOptions{Multikey: true, Unique: true})
+
+	if _, err := col.Put(taggedDoc("1", []any{1, 2, 2})); err != nil {
+		t.Fatalf("expected repeated elements in one document to be accepted, got %v", err)
+	}
+	if _, err := col.Put(taggedDoc("2", []any{3, 2})); !errors.Is(err, ErrUniqueConstraintViolation) {
+		t.Fatalf("expected ErrUniqueConstraintViolation, got %v", err)
+	}
+	if _, err := col.Put(taggedDoc("2", []any{3.5, 10})); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	assertIDs(t, queryIDs(t, col, "tags", QueryParams{MinValue: number(3)}), "2")
+}
+
+func TestIndex_WithoutMultikeySkipsArrays(t *testing.T) {
+	col, _ := NewStore().CreateCollection("posts", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(taggedDoc("1", []any{"go"}))
+	col.CreateIndex("tags")
+
+	assertIDs(t, queryIDs(t, col, "tags", QueryParams{}))
+}
@@ -0,0 +1,148 @@
+package document_store
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// FieldValueError names a document field that cannot be stored and why. It
+// matches ErrUnsupportedDocumentField with errors.Is.
+type FieldValueError struct {
+	// Field is a dotted path such as "address.city", with array elements
+	// written as "tags[1]", or empty for the document itself.
+	Field  string
+	Reason string
+}
+
+func (e *FieldValueError) Error() string {
+	return fmt.Sprintf("%v: %s: %s", ErrUnsupportedDocumentField, schemaPath(e.Field), e.Reason)
+}
+
+func (e *FieldValueError) Unwrap() error {
+	return ErrUnsupportedDocumentField
+}
+
+// prepare checks that every field of doc holds a value of its declared type
+// and returns the document to store, a copy of doc with normalized fields,
+// and its primary key. A generated key is also added to doc.
+func (c *CollectionImpl) prepare(doc *Document) (*Document, string, error) {
+	if doc == nil {
+		return nil, "", &FieldValueError{Reason: "document is nil"}
+	}
+	fields, err := normalizeFields(doc.Fields)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, exists := fields[c.config.PrimaryKey]; !exists && c.config.GenerateKeys {
+		field := DocumentField{Type: DocumentFieldTypeString, Value: c.keys.next(c.now())}
+		fields[c.config.PrimaryKey] = field
+		if doc.Fields == nil {
+			doc.Fields = make(map[string]DocumentField)
+		}
+		doc.Fields[c.config.PrimaryKey] = field
+	}
+	// The expiry is only kept from doc when the log is replayed.
+	prepared := &Document{Fields: fields, ExpiresAt: cloneTime(doc.ExpiresAt)}
+	key, err := c.primaryKey(prepared)
+	if err != nil {
+		return nil, "", err
+	}
+	return prepared, key, nil
+}
+
+// normalizeFields returns a copy of fields in which numbers are float64,
+// arrays are []any and objects are map[string]any, at every depth.
+func normalizeFields(fields map[string]DocumentField) (map[string]DocumentField, error) {
+	normalized := make(map[string]DocumentField, len(fields))
+	for name, field := range fields {
+		if name == "" {
+			return nil, &FieldValueError{Reason: "empty field name"}
+		}
+		var err error
+		if normalized[name], err = normalizeField(name, field); err != nil {
+			return nil, err
+		}
+	}
+	return normalized, nil
+}
+
+func normalizeField(path string, field DocumentField) (DocumentField, error) {
+	switch field.Type {
+	case DocumentFieldTypeString, DocumentFieldTypeNumber, DocumentFieldTypeBool,
+		DocumentFieldTypeArray, DocumentFieldTypeObject:
+	default:
+		return DocumentField{}, &FieldValueError{Field: path, Reason: fmt.Sprintf("unknown type %q", field.Type)}
+	}
+	value, actual, err := normalizeValue(path, field.Value)
+	if err != nil {
+		return DocumentField{}, err
+	}
+	if actual != field.Type {
+		return DocumentField{}, &FieldValueError{Field: path, Reason: fmt.Sprintf("declared as %s but holds %s", field.Type, actual)}
+	}
+	return DocumentField{Type: field.Type, Value: value}, nil
+}
+
+// normalizeValue returns the normalized form of a value nested in a field
+// and its type. Values nested in objects may also be DocumentFields.
+func normalizeValue(path string, value any) (any, DocumentFieldType, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, "", &FieldValueError{Field: path, Reason: "null values are not supported"}
+	case DocumentField:
+		field, err := normalizeField(path, v)
+		return field.Value, field.Type, err
+	case string:
+		return v, DocumentFieldTypeString, nil
+	case bool:
+		return v, DocumentFieldTypeBool, nil
+	case map[string]any:
+		object := make(map[string]any, len(v))
+		for name, child := range v {
+			var err error
+			if object[name], _, err = normalizeValue(joinPath(path, name), child); err != nil {
+				return nil, "", err
+			}
+		}
+		return object, DocumentFieldTypeObject, nil
+	case map[string]DocumentField:
+		object := make(map[string]any, len(v))
+		for name, child := range v {
+			field, err := normalizeField(joinPath(path, name), child)
+			if err != nil {
+				return nil, "", err
+			}
+			object[name] = field.Value
+		}
+		return object, DocumentFieldTypeObject, nil
+	}
+
+	if n, ok := numberValue(value); ok {
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, "", &FieldValueError{Field: path, Reason: "number must be finite"}
+		}
+		return n, DocumentFieldTypeNumber, nil
+	}
+	if kind := reflect.ValueOf(value).Kind(); kind == reflect.Slice || kind == reflect.Array {
+		elements := arrayElements(value)
+		array := make([]any, len(elements))
+		for i, element := range elements {
+			var err error
+			if array[i], _, err = normalizeValue(path+"["+strconv.Itoa(i)+"]", element); err != nil {
+				return nil, "", err
+			}
+		}
+		return array, DocumentFieldTypeArray, nil
+	}
+	return nil, "", &FieldValueError{Field: path, Reason: fmt.Sprintf("unsupported value of type %T", value)}
+}
+
+// boundError explains why a query bound has no index encoding.
+func boundError(name string, field DocumentField) error {
+	if _, err := normalizeField(name, field); err != nil {
+		return err
+	}
+	return &FieldValueError{Field: name, Reason: "only strings, numbers and booleans can bound a query"}
+}
@@ -0,0 +1,91 @@
+package document_store
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPut_NormalizesValues(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("age")
+	doc := &Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "1"},
+		"age":  {Type: DocumentFieldTypeNumber, Value: 30},
+		"tags": {Type: DocumentFieldTypeArray, Value: []string{"a", "b"}},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]DocumentField{
+			"zip":  {Type: DocumentFieldTypeNumber, Value: uint16(1001)},
+			"geo":  {Type: DocumentFieldTypeArray, Value: []float32{1.5, 2}},
+			"city": {Type: DocumentFieldTypeString, Value: "Kyiv"},
+		}},
+	}}
+	if _, err := col.Put(doc); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+
+	stored, _ := col.Get("1")
+	want := map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "1"},
+		"age":  {Type: DocumentFieldTypeNumber, Value: 30.0},
+		"tags": {Type: DocumentFieldTypeArray, Value: []any{"a", "b"}},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]any{
+			"zip":  1001.0,
+			"geo":  []any{1.5, 2.0},
+			"city": "Kyiv",
+		}},
+	}
+	if !reflect.DeepEqual(stored.Fields, want) {
+		t.Fatalf("expected %v, got %v", want, stored.Fields)
+	}
+	if docs, _ := col.Query("age", QueryParams{MinValue: &DocumentField{Type: DocumentFieldTypeNumber, Value: int64(30)}}); len(docs) != 1 {
+		t.Fatalf("expected an int bound to match the normalized number, got %v", docs)
+	}
+}
+
+func TestPut_RejectsInvalidFields(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	withField := func(name string, field DocumentField) *Document {
+		doc := userDoc("1", "Alice")
+		doc.Fields[name] = field
+		return doc
+	}
+
+	tests := []struct {
+		doc  *Document
+		want FieldValueError
+	}{
+		{nil, FieldValueError{Reason: "document is nil"}},
+		{&Document{Fields: map[string]DocumentField{"name": *str("x")}}, FieldValueError{Field: "id", Reason: "primary key is missing"}},
+		{withField("id", *number(1)), FieldValueError{Field: "id", Reason: "primary key must be a string, got number"}},
+		{withField("age", DocumentField{Type: "integer", Value: 1}), FieldValueError{Field: "age", Reason: `unknown type "integer"`}},
+		{withField("age", DocumentField{Type: DocumentFieldTypeNumber, Value: "abc"}), FieldValueError{Field: "age", Reason: "declared as number but holds string"}},
+		{withField("age", DocumentField{Type: DocumentFieldTypeNumber, Value: math.NaN()}), FieldValueError{Field: "age", Reason: "number must be finite"}},
+		{withField("tags", DocumentField{Type: DocumentFieldTypeArray, Value: []any{"a", nil}}), FieldValueError{Field: "tags[1]", Reason: "null values are not supported"}},
+		{withField("address", DocumentField{Type: DocumentFieldTypeObject, Value: map[string]any{"at": struct{}{}}}), FieldValueError{Field: "address.at", Reason: "unsupported value of type struct {}"}},
+		{withField("address", DocumentField{Type: DocumentFieldTypeObject, Value: map[string]DocumentField{"zip": {Type: DocumentFieldTypeBool, Value: 1}}}), FieldValueError{Field: "address.zip", Reason: "declared as bool but holds number"}},
+	}
+	for _, tt := range tests {
+		var fieldErr *FieldValueError
+		_, err := col.Put(tt.doc)
+		if !errors.As(err, &fieldErr) || *fieldErr != tt.want || !errors.Is(err, ErrUnsupportedDocumentField) {
+			t.Fatalf("expected %+v, got %v", tt.want, err)
+		}
+	}
+	if len(col.documents) != 0 {
+		t.Fatalf("expected no document to be stored, got %d", len(col.documents))
+	}
+
+	col.CreateIndex("name")
+	bad := withField("age", DocumentField{Type: DocumentFieldTypeNumber, Value: "abc"})
+	if _, errs := col.PutMany([]*Document{bad}); !errors.Is(errs[0], ErrUnsupportedDocumentField) {
+		t.Fatalf("expected PutMany to reject the document, got %v", errs[0])
+	}
+	if _, err := store.Begin().Put("users", bad); !errors.Is(err, ErrUnsupportedDocumentField) {
+		t.Fatalf("expected Tx.Put to reject the document, got %v", err)
+	}
+	if _, err := col.Query("name", QueryParams{MinValue: &DocumentField{Type: DocumentFieldTypeString, Value: 1}}); !errors.Is(err, ErrUnsupportedDocumentField) {
+		t.Fatalf("expected an invalid bound to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package document_store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageParams limit a result to Limit documents (0 means all) after skipping
+// Skip of them. Cursor continues from the NextCursor of a previous page; it
+// records the position of the last returned document rather than an offset,
+// so writes between pages neither repeat nor drop the documents that stay.
+type PageParams struct {
+	Limit  int
+	Skip   int
+	Cursor string
+}
+
+type Page struct {
+	Docs       []Document
+	NextCursor string
+}
+
+func (p *PageParams) validate() error {
+	if p.Limit < 0 || p.Skip < 0 {
+		return ErrInvalidQuery
+	}
+	return nil
+}
+
+// cursor is the position of a document in a result: its index key for a
+// query, its sort values for a sorted list, and its primary key.
+type cursor struct {
+	Key    string
+	Values []string
+	ID     string
+}
+
+// cursorWire holds encoded values as bytes, since they are not valid UTF-8.
+type cursorWire struct {
+	Key    []byte   `json:"k,omitempty"`
+	Values [][]byte `json:"v,omitempty"`
+	ID     string   `json:"id"`
+}
+
+func (c cursor) encode() string {
+	w := cursorWire{Key: []byte(c.Key), ID: c.ID}
+	for _, value := range c.Values {
+		w.Values = append(w.Values, []byte(value))
+	}
+	data, _ := json.Marshal(w)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var w cursorWire
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &cursor{Key: string(w.Key), ID: w.ID}
+	for _, value := range w.Values {
+		c.Values = append(c.Values, string(value))
+	}
+	return c, nil
+}
+
+// pager collects documents in result order until the page is full.
+type pager struct {
+	params PageParams
+	docs   []Document
+	last   cursor
+	more   bool
+}
+
+func newPager(params PageParams) *pager {
+	return &pager{params: params}
+}
+
+// add returns false once the page is full and doc is known to follow it.
+func (p *pager) add(doc *Document, position cursor) bool {
+	if p.params.Skip > 0 {
+		p.params.Skip--
+		return true
+	}
+	if p.params.Limit > 0 && len(p.docs) == p.params.Limit {
+		p.more = true
+		return false
+	}
+	p.docs = append(p.docs, *cloneDocument(doc))
+	p.last = position
+	return true
+}
+
+func (p *pager) page() *Page {
+	page := &Page{Docs: p.docs}
+	if p.more {
+		page.NextCursor = p.last.encode()
+	}
+	return page
+}
+
+func (c *CollectionImpl) project(page *Page, projection *Projection) *Page {
+	for i := range page.Docs {
+		page.Docs[i] = projection.apply(page.Docs[i], c.keyFields())
+	}
+	return page
+}
+
+// firstKey returns the key a scan in the given direction reaches first among
+// the sorted keys that are in range.
+func firstKey(keys []string, inRange func(string) bool, desc bool) string {
+	for n := range keys {
+		key := keys[n]
+		if desc {
+			key = keys[len(keys)-1-n]
+		}
+		if inRange(key) {
+			return key
+		}
+	}
+	return ""
+}
@@ -0,0 +1,207 @@
+package document_store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func pageIDs(page *Page) []string {
+	ids := make([]string, 0, len(page.Docs))
+	for _, doc := range page.Docs {
+		ids = append(ids, doc.Fields["id"].Value.(string))
+	}
+	return ids
+}
+
+func newNumberedCollection(t *testing.T, n int) *CollectionImpl {
+	t.Helper()
+	col, _ := NewStore().CreateCollection("items", &CollectionConfig{PrimaryKey: "id"})
+	for i := 0; i < n; i++ {
+		col.Put(&Document{Fields: map[string]DocumentField{
+			"id":    {Type: DocumentFieldTypeString, Value: fmt.Sprintf("%02d", i)},
+			"group": {Type: DocumentFieldTypeNumber, Value: i % 3},
+		}})
+	}
+	return col
+}
+
+func TestListPage_LimitAndCursor(t *testing.T) {
+	col := newNumberedCollection(t, 5)
+
+	page, err := col.ListPage(ListParams{PageParams: PageParams{Limit: 2}})
+	if err != nil {
+		t.Fatalf("unexpected error on ListPage: %v", err)
+	}
+	assertIDs(t, pageIDs(page), "00", "01")
+	if page.NextCursor == "" {
+		t.Fatal("expected a next cursor")
+	}
+
+	page, _ = col.ListPage(ListParams{PageParams: PageParams{Limit: 2, Cursor: page.NextCursor}})
+	assertIDs(t, pageIDs(page), "02", "03")
+
+	page, _ = col.ListPage(ListParams{PageParams: PageParams{Limit: 2, Cursor: page.NextCursor}})
+	assertIDs(t, pageIDs(page), "04")
+	if page.NextCursor != "" {
+		t.Fatal("expected no next cursor on the last page")
+	}
+
+	page, _ = col.ListPage(ListParams{PageParams: PageParams{Limit: 2, Skip: 3}})
+	assertIDs(t, pageIDs(page), "03", "04")
+
+	// A full last page has no cursor when nothing follows it.
+	page, _ = col.ListPage(ListParams{PageParams: PageParams{Limit: 5}})
+	if len(page.Docs) != 5 || page.NextCursor != "" {
+		t.Fatalf("expected all documents without a cursor, got %v %q", pageIDs(page), page.NextCursor)
+	}
+}
+
+func TestListPage_StableAcrossWrites(t *testing.T) {
+	col := newNumberedCollection(t, 5)
+
+	page, _ := col.ListPage(ListParams{PageParams: PageParams{Limit: 2}})
+	assertIDs(t, pageIDs(page), "00", "01")
+
+	// Removing a returned document and adding one before the cursor do not
+	// shift the next page.
+	col.Delete("00")
+	col.Put(&Document{Fields: map[string]DocumentField{"id": {Type: DocumentFieldTypeString, Value: "005"}}})
+	col.Delete("02")
+
+	page, _ = col.ListPage(ListParams{PageParams: PageParams{Limit: 2, Cursor: page.NextCursor}})
+	assertIDs(t, pageIDs(page), "03", "04")
+}
+
+func TestQueryPage_LimitAndCursor(t *testing.T) {
+	col := newNumberedCollection(t, 7)
+	col.CreateIndex("group")
+
+	var all []string
+	params := QueryParams{PageParams: PageParams{Limit: 2}}
+	for {
+		page, err := col.QueryPage("group", params)
+		if err != nil {
+			t.Fatalf("unexpected error on QueryPage: %v", err)
+		}
+		all = append(all, pageIDs(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+	assertIDs(t, all, "00", "03", "06", "01", "04", "02", "05")
+
+	desc := QueryParams{Desc: true, PageParams: PageParams{Limit: 3}}
+	page, _ := col.QueryPage("group", desc)
+	assertIDs(t, pageIDs(page), "05", "02", "04")
+	desc.Cursor = page.NextCursor
+	page, _ = col.QueryPage("group", desc)
+	assertIDs(t, pageIDs(page), "01", "06", "03")
+
+	ranged := QueryParams{MinValue: number(1), PageParams: PageParams{Limit: 1, Skip: 1}}
+	page, _ = col.QueryPage("group", ranged)
+	assertIDs(t, pageIDs(page), "04")
+	ranged.Skip = 0
+	ranged.Cursor = page.NextCursor
+	page, _ = col.QueryPage("group", ranged)
+	assertIDs(t, pageIDs(page), "02")
+}
+
+func TestQueryPage_MultikeyNotRepeatedAcrossPages(t *testing.T) {
+	col, _ := NewStore().CreateCollection("posts", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("tags", IndexOptions{Multikey: true})
+	col.Put(taggedDoc("1", []any{"a", "c"}))
+	col.Put(taggedDoc("2", []any{"b"}))
+	col.Put(taggedDoc("3", []any{"a", "d"}))
+
+	var all []string
+	params := QueryParams{PageParams: PageParams{Limit: 1}}
+	for {
+		page, _ := col.QueryPage("tags", params)
+		all = append(all, pageIDs(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+	assertIDs(t, all, "1", "3", "2")
+
+	ranged := QueryParams{MinValue: str("b"), MaxValue: str("d")}
+	assertIDs(t, queryIDs(t, col, "tags", ranged), "2", "1", "3")
+}
+
+func TestQueryPage_TiesInNumericKeyOrder(t *testing.T) {
+	col, _ := NewStore().CreateCollection("items", &CollectionConfig{PrimaryKey: "id", NumericKey: true})
+	col.CreateIndex("group")
+	var docs []*Document
+	for _, id := range []float64{10, 2, 33, 1} {
+		docs = append(docs, &Document{Fields: map[string]DocumentField{"id": *number(id), "group": *str("a")}})
+	}
+	col.PutMany(docs)
+	col.Put(&Document{Fields: map[string]DocumentField{"id": *number(5), "group": *str("a")}})
+
+	for _, tt := range []struct {
+		desc bool
+		want []string
+	}{
+		{false, []string{"1", "2", "5", "10", "33"}},
+		{true, []string{"33", "10", "5", "2", "1"}},
+	} {
+		var all []string
+		params := QueryParams{Desc: tt.desc, PageParams: PageParams{Limit: 2}}
+		for {
+			page, err := col.QueryPage("group", params)
+			if err != nil {
+				t.Fatalf("unexpected error on QueryPage: %v", err)
+			}
+			for _, doc := range page.Docs {
+				key, _ := col.documentKey(&doc)
+				all = append(all, key)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			params.Cursor = page.NextCursor
+		}
+		assertIDs(t, all, tt.want...)
+	}
+}
+
+func TestPage_InvalidParams(t *testing.T) {
+	col := newNumberedCollection(t, 2)
+	col.CreateIndex("group")
+
+	if _, err := col.ListPage(ListParams{PageParams: PageParams{Cursor: "%%%"}}); err != ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := col.QueryPage("group", QueryParams{PageParams: PageParams{Cursor: "bm90LWpzb24"}}); err != ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := col.ListPage(ListParams{PageParams: PageParams{Limit: -1}}); err != ErrInvalidQuery {
+		t.Fatalf("expected ErrInvalidQuery, got %v", err)
+	}
+}
+
+func BenchmarkQueryPage(b *testing.B) {
+	for _, numeric := range []bool{false, true} {
+		col, _ := NewStore().CreateCollection("items", &CollectionConfig{PrimaryKey: "id", NumericKey: numeric})
+		col.CreateIndex("group")
+		docs := make([]*Document, 100000)
+		for i := range docs {
+			id := DocumentField{Type: DocumentFieldTypeString, Value: fmt.Sprint(i)}
+			if numeric {
+				id = *number(float64(i))
+			}
+			docs[i] = &Document{Fields: map[string]DocumentField{"id": id, "group": *str("a")}}
+		}
+		col.PutMany(docs)
+		page, _ := col.QueryPage("group", QueryParams{PageParams: PageParams{Limit: 10}})
+		params := QueryParams{PageParams: PageParams{Limit: 10, Cursor: page.NextCursor}}
+
+		b.Run(fmt.Sprintf("NumericKey=%v", numeric), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				col.QueryPage("group", params)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package document_store
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// Patch describes a partial update of a document. Set replaces fields, Unset
+// removes them, Inc adds to number fields, Push appends a value to array
+// fields and Pull removes every element equal to a value from them. Missing
+// fields are created by Set, Inc and Push, along with the objects on their
+// path. Paths may be dotted; the primary key cannot be changed.
+// The operations are applied in that order, each in the order of its paths.
+type Patch struct {
+	Set   map[string]DocumentField
+	Unset []string
+	Inc   map[string]float64
+	Push  map[string]DocumentField
+	Pull  map[string]DocumentField
+}
+
+func (p *Patch) validate(keyFields []string) error {
+	var paths []string
+	paths = append(paths, mapKeys(p.Set)...)
+	paths = append(paths, p.Unset...)
+	paths = append(paths, mapKeys(p.Inc)...)
+	paths = append(paths, mapKeys(p.Push)...)
+	paths = append(paths, mapKeys(p.Pull)...)
+	for _, path := range paths {
+		if path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") {
+			return ErrInvalidPatch
+		}
+		for _, key := range keyFields {
+			if path == key || strings.HasPrefix(path, key+".") {
+				return ErrInvalidPatch
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Patch) apply(fields map[string]DocumentField) error {
+	for _, path := range mapKeys(p.Set) {
+		if err := updatePath(fields, path, setField(p.Set[path])); err != nil {
+			return err
+		}
+	}
+	for _, path := range p.Unset {
+		if err := updatePath(fields, path, unsetField); err != nil {
+			return err
+		}
+	}
+	for _, path := range mapKeys(p.Inc) {
+		if err := updatePath(fields, path, incField(p.Inc[path])); err != nil {
+			return err
+		}
+	}
+	for _, path := range mapKeys(p.Push) {
+		if err := updatePath(fields, path, pushField(p.Push[path])); err != nil {
+			return err
+		}
+	}
+	for _, path := range mapKeys(p.Pull) {
+		if err := updatePath(fields, path, pullField(p.Pull[path])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fieldUpdate returns the new value of a field and whether the field is kept.
+type fieldUpdate func(field DocumentField, exists bool) (DocumentField, bool, error)
+
+func setField(value DocumentField) fieldUpdate {
+	return func(DocumentField, bool) (DocumentField, bool, error) {
+		return value, true, nil
+	}
+}
+
+func unsetField(DocumentField, bool) (DocumentField, bool, error) {
+	return DocumentField{}, false, nil
+}
+
+func incField(amount float64) fieldUpdate {
+	return func(field DocumentField, exists bool) (DocumentField, bool, error) {
+		if !exists {
+			return DocumentField{Type: DocumentFieldTypeNumber, Value: amount}, true, nil
+		}
+		value, ok := numberValue(field.Value)
+		if field.Type != DocumentFieldTypeNumber || !ok {
+			return DocumentField{}, false, ErrInvalidPatch
+		}
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: value + amount}, true, nil
+	}
+}
+
+func pushField(value DocumentField) fieldUpdate {
+	return func(field DocumentField, exists bool) (DocumentField, bool, error) {
+		if !exists {
+			return DocumentField{Type: DocumentFieldTypeArray, Value: []any{value.Value}}, true, nil
+		}
+		if field.Type != DocumentFieldTypeArray {
+			return DocumentField{}, false, ErrInvalidPatch
+		}
+		elements := arrayElements(field.Value)
+		pushed := make([]any, len(elements), len(elements)+1)
+		copy(pushed, elements)
+		pushed = append(pushed, value.Value)
+		return DocumentField{Type: DocumentFieldTypeArray, Value: pushed}, true, nil
+	}
+}
+
+func pullField(value DocumentField) fieldUpdate {
+	return func(field DocumentField, exists bool) (DocumentField, bool, error) {
+		if !exists {
+			return DocumentField{}, false, nil
+		}
+		if field.Type != DocumentFieldTypeArray {
+			return DocumentField{}, false, ErrInvalidPatch
+		}
+		pulled := make([]any, 0)
+		for _, element := range arrayElements(field.Value) {
+			if !sameValue(element, value) {
+				pulled = append(pulled, element)
+			}
+		}
+		return DocumentField{Type: DocumentFieldTypeArray, Value: pulled}, true, nil
+	}
+}
+
+// sameValue compares scalars by their index encoding, so numbers of
+// different Go types are equal, and other values deeply.
+func sameValue(element any, value DocumentField) bool {
+	if field, ok := fieldFromValue(element); ok {
+		a, okA := encodeIndexValue(field)
+		b, okB := encodeIndexValue(value)
+		if okA && okB {
+			return a == b
+		}
+	}
+	return reflect.DeepEqual(element, value.Value)
+}
+
+// updatePath applies update to the field at path. It copies the objects
+// along the path instead of changing them, since they are shared with the
+// stored document.
+func updatePath(fields map[string]DocumentField, path string, update fieldUpdate) error {
+	name, rest, nested := strings.Cut(path, ".")
+	if field, exists := fields[path]; exists || !nested {
+		updated, keep, err := update(field, exists)
+		if err != nil {
+			return err
+		}
+		if keep {
+			fields[path] = updated
+		} else {
+			delete(fields, path)
+		}
+		return nil
+	}
+
+	parent, exists := fields[name]
+	object, changed, err := updateObject(parent.Value, exists, strings.Split(rest, "."), update)
+	if err != nil || !changed {
+		return err
+	}
+	fields[name] = DocumentField{Type: DocumentFieldTypeObject, Value: object}
+	return nil
+}
+
+// updateObject returns a copy of an object value with update applied at the
+// path, or false if nothing changes. A missing object is created when the
+// update adds a field.
+func updateObject(value any, exists bool, parts []string, update fieldUpdate) (map[string]any, bool, error) {
+	entries, ok := objectEntries(value)
+	if exists && !ok {
+		// A value that is not an object cannot hold the field.
+		if _, keep, err := update(DocumentField{}, false); err != nil || keep {
+			return nil, false, ErrInvalidPatch
+		}
+		return nil, false, nil
+	}
+
+	object := make(map[string]any, len(entries)+1)
+	for k, v := range entries {
+		if field, ok := v.(DocumentField); ok {
+			v = field.Value
+		}
+		object[k] = v
+	}
+	child, childExists := object[parts[0]]
+
+	if len(parts) > 1 {
+		nested, changed, err := updateObject(child, childExists, parts[1:], update)
+		if err != nil || !changed {
+			return nil, false, err
+		}
+		object[parts[0]] = nested
+		return object, true, nil
+	}
+
+	field, _ := fieldFromValue(child)
+	updated, keep, err := update(field, childExists)
+	if err != nil {
+		return nil, false, err
+	}
+	if !keep {
+		if !childExists {
+			return nil, false, nil
+		}
+		delete(object, parts[0])
+		return object, true, nil
+	}
+	object[parts[0]] = updated.Value
+	return object, true, nil
+}
+
+// Update applies patch to the document stored under key and returns the
+// updated document. The patch is applied under the collection lock, so
+// concurrent updates of different fields do not overwrite each other.
+func (c *CollectionImpl) Update(key string, patch Patch) (*Document, error) {
+	if err := patch.validate(c.keyFields()); err != nil {
+		return nil, err
+	}
+	c.lockWrite()
+	defer c.unlockWrite()
+	doc, err := c.patched(key, &patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.put(key, doc); err != nil {
+		return nil, err
+	}
+	return cloneDocument(doc), nil
+}
+
+// patched returns a new document holding the stored document under key with
+// a validated patch applied. Must be called with the write lock held.
+func (c *CollectionImpl) patched(key string, patch *Patch) (*Document, error) {
+	oldDoc := c.live(c.documents[key])
+	if oldDoc == nil {
+		return nil, ErrDocumentNotFound
+	}
+	fields := make(map[string]DocumentField, len(oldDoc.Fields))
+	for name, field := range oldDoc.Fields {
+		fields[name] = field
+	}
+	if err := patch.apply(fields); err != nil {
+		return nil, err
+	}
+	fields, err := normalizeFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Fields: fields}, nil
+}
@@ -0,0 +1,157 @@
+package document_store
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestUpdate_Operators(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	doc := userDoc("1", "Alice")
+	doc.Fields["age"] = DocumentField{Type: DocumentFieldTypeNumber, Value: 30}
+	doc.Fields["nickname"] = DocumentField{Type: DocumentFieldTypeString, Value: "al"}
+	doc.Fields["tags"] = DocumentField{Type: DocumentFieldTypeArray, Value: []any{"a", 1.0, "b", 1.0}}
+	col.Put(doc)
+
+	updated, err := col.Update("1", Patch{
+		Set:   map[string]DocumentField{"name": *str("Alicia")},
+		Unset: []string{"nickname", "missing"},
+		Inc:   map[string]float64{"age": 2, "logins": 1},
+		Push:  map[string]DocumentField{"tags": *str("c"), "roles": *str("admin")},
+		Pull:  map[string]DocumentField{"tags": *number(1), "missing": *str("x")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error on Update: %v", err)
+	}
+	want := map[string]DocumentField{
+		"id":     {Type: DocumentFieldTypeString, Value: "1"},
+		"name":   {Type: DocumentFieldTypeString, Value: "Alicia"},
+		"age":    {Type: DocumentFieldTypeNumber, Value: 32.0},
+		"logins": {Type: DocumentFieldTypeNumber, Value: 1.0},
+		"tags":   {Type: DocumentFieldTypeArray, Value: []any{"a", "b", "c"}},
+		"roles":  {Type: DocumentFieldTypeArray, Value: []any{"admin"}},
+	}
+	if !reflect.DeepEqual(updated.Fields, want) {
+		t.Fatalf("expected %v, got %v", want, updated.Fields)
+	}
+	stored, _ := col.Get("1")
+	if !reflect.DeepEqual(stored.Fields, want) {
+		t.Fatalf("expected stored %v, got %v", want, stored.Fields)
+	}
+}
+
+func TestUpdate_NestedPaths(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	address := map[string]any{"city": "Kyiv", "geo": map[string]any{"lat": 50.45}}
+	col.Put(addressDoc("1", address))
+
+	updated, err := col.Update("1", Patch{
+		Set:   map[string]DocumentField{"address.geo.lng": *number(30.52), "profile.bio": *str("hi")},
+		Unset: []string{"address.city", "address.zip.code"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error on Update: %v", err)
+	}
+	want := map[string]DocumentField{
+		"id":      {Type: DocumentFieldTypeString, Value: "1"},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]any{"geo": map[string]any{"lat": 50.45, "lng": 30.52}}},
+		"profile": {Type: DocumentFieldTypeObject, Value: map[string]any{"bio": "hi"}},
+	}
+	if !reflect.DeepEqual(updated.Fields, want) {
+		t.Fatalf("expected %v, got %v", want, updated.Fields)
+	}
+	if address["city"] != "Kyiv" || len(address["geo"].(map[string]any)) != 1 {
+		t.Fatalf("expected the previous document's objects to be unchanged, got %v", address)
+	}
+}
+
+func TestUpdate_MaintainsIndexes(t *testing.T) {
+	col := newPeopleCollection(t)
+	col.CreateIndex("age")
+
+	if _, err := col.Update("2", Patch{Inc: map[string]float64{"age": 100}}); err != nil {
+		t.Fatalf("unexpected error on Update: %v", err)
+	}
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{}), "4", "1", "5", "3", "2")
+
+	if _, err := col.Update("3", Patch{Unset: []string{"age"}}); err != nil {
+		t.Fatalf("unexpected error on Update: %v", err)
+	}
+	assertIDs(t, queryIDs(t, col, "age", QueryParams{}), "4", "1", "5", "2")
+}
+
+func TestUpdate_Errors(t *testing.T) {
+	col := newPeopleCollection(t)
+	col.CreateIndex("age", IndexOptions{Fields: []string{"age"}, Unique: true})
+
+	tests := []struct {
+		key   string
+		patch Patch
+		want  error
+	}{
+		{"missing", Patch{Set: map[string]DocumentField{"age": *number(1)}}, ErrDocumentNotFound},
+		{"1", Patch{Set: map[string]DocumentField{"id": *str("9")}}, ErrInvalidPatch},
+		{"1", Patch{Unset: []string{""}}, ErrInvalidPatch},
+		{"1", Patch{Inc: map[string]float64{"active": 1}}, ErrInvalidPatch},
+		{"1", Patch{Push: map[string]DocumentField{"age": *number(1)}}, ErrInvalidPatch},
+		{"1", Patch{Set: map[string]DocumentField{"active.flag": *boolean(true)}}, ErrInvalidPatch},
+		{"1", Patch{Set: map[string]DocumentField{"name": *str("x")}, Inc: map[string]float64{"name": 1}}, ErrInvalidPatch},
+		{"1", Patch{Set: map[string]DocumentField{"age": *number(9)}}, ErrUniqueConstraintViolation},
+	}
+	for _, tt := range tests {
+		if _, err := col.Update(tt.key, tt.patch); !errors.Is(err, tt.want) {
+			t.Fatalf("expected %v for %+v, got %v", tt.want, tt.patch, err)
+		}
+	}
+
+	doc, _ := col.Get("1")
+	if len(doc.Fields) != 3 || doc.Fields["age"].Value != 25.0 {
+		t.Fatalf("expected failed updates to leave the document unchanged, got %v", doc.Fields)
+	}
+}
+
+func TestUpdate_ConcurrentFieldsDoNotOverwrite(t *testing.T) {
+	col, _ := NewStore().CreateCollection("counters", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(userDoc("1", "c"))
+
+	var wg sync.WaitGroup
+	for _, field := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				col.Update("1", Patch{Inc: map[string]float64{field: 1}})
+			}
+		}()
+	}
+	wg.Wait()
+
+	doc, _ := col.Get("1")
+	if doc.Fields["a"].Value != 100.0 || doc.Fields["b"].Value != 100.0 {
+		t.Fatalf("expected both counters at 100, got %v", doc.Fields)
+	}
+}
+
+func TestUpdate_ReplayedFromWAL(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStoreFromWAL(dir)
+	users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	users.Put(userDoc("1", "Alice"))
+	if _, err := users.Update("1", Patch{Set: map[string]DocumentField{"name": *str("Alicia")}}); err != nil {
+		t.Fatalf("unexpected error on Update: %v", err)
+	}
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	col, _ := reopened.GetCollection("users")
+	doc, err := col.Get("1")
+	if err != nil || doc.Fields["name"].Value != "Alicia" {
+		t.Fatalf("expected updated name after replay, got %v, %v", doc, err)
+	}
+}
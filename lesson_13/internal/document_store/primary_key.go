@@ -0,0 +1,152 @@
+package document_store
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+var ErrInvalidKey = errors.New("invalid key")
+var ErrInvalidConfig = errors.New("invalid collection config")
+
+// EncodeKey returns the canonical key of a document whose primary key fields
+// hold values, in order: a string as is, a number in its shortest decimal
+// form, and the values of a composite key as a JSON array such as
+// ["acme",7]. Get, Delete and the other methods taking a key expect it in
+// this form, and the documents of a dump are stored under it.
+func EncodeKey(values ...any) (string, error) {
+	parts := make([]any, len(values))
+	for i, value := range values {
+		if field, ok := value.(DocumentField); ok {
+			value = field.Value
+		}
+		if s, ok := value.(string); ok {
+			parts[i] = s
+			continue
+		}
+		n, ok := numberValue(value)
+		if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+			return "", fmt.Errorf("%w: %v is not a string or a finite number", ErrInvalidKey, value)
+		}
+		// Negative zero is the same key as zero.
+		parts[i] = n + 0
+	}
+
+	switch {
+	case len(parts) == 0:
+		return "", ErrInvalidKey
+	case len(parts) > 1:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(parts)
+		return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+	}
+	if n, ok := parts[0].(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	}
+	return parts[0].(string), nil
+}
+
+func (config *CollectionConfig) validate() error {
+	if len(config.KeyFields) > 0 && (config.PrimaryKey != "" || config.NumericKey || config.GenerateKeys) {
+		return fmt.Errorf("%w: KeyFields replaces PrimaryKey, NumericKey and GenerateKeys", ErrInvalidConfig)
+	}
+	if config.NumericKey && config.GenerateKeys {
+		return fmt.Errorf("%w: generated keys are strings", ErrInvalidConfig)
+	}
+	seen := make(map[string]bool)
+	for _, name := range config.KeyFields {
+		if name == "" || seen[name] {
+			return fmt.Errorf("%w: key field %q is empty or repeated", ErrInvalidConfig, name)
+		}
+		seen[name] = true
+	}
+	return config.Schema.validate("")
+}
+
+// keyFields returns the fields of the primary key, in order.
+func (c *CollectionImpl) keyFields() []string {
+	if len(c.config.KeyFields) > 0 {
+		return c.config.KeyFields
+	}
+	return []string{c.config.PrimaryKey}
+}
+
+// stringKey reports whether the key is a single string field, the one kind
+// of key whose canonical form also sorts like the key.
+func (c *CollectionImpl) stringKey() bool {
+	return len(c.config.KeyFields) == 0 && !c.config.NumericKey
+}
+
+// primaryKey returns the canonical key of doc, or an error naming the key
+// field it lacks or holding a value of the wrong type.
+func (c *CollectionImpl) primaryKey(doc *Document) (string, error) {
+	fields := c.keyFields()
+	values := make([]any, len(fields))
+	for i, name := range fields {
+		field, exists := doc.Fields[name]
+		if !exists {
+			return "", &FieldValueError{Field: name, Reason: "primary key is missing"}
+		}
+		switch {
+		case len(c.config.KeyFields) > 0:
+			if field.Type != DocumentFieldTypeString && field.Type != DocumentFieldTypeNumber {
+				return "", &FieldValueError{Field: name, Reason: "primary key must be a string or a number, got " + string(field.Type)}
+			}
+		case c.config.NumericKey:
+			if field.Type != DocumentFieldTypeNumber {
+				return "", &FieldValueError{Field: name, Reason: "primary key must be a number, got " + string(field.Type)}
+			}
+		case field.Type != DocumentFieldTypeString:
+			return "", &FieldValueError{Field: name, Reason: "primary key must be a string, got " + string(field.Type)}
+		}
+		values[i] = field.Value
+	}
+	key, err := EncodeKey(values...)
+	if err != nil {
+		return "", &FieldValueError{Field: fields[0], Reason: err.Error()}
+	}
+	return key, nil
+}
+
+func (c *CollectionImpl) documentKey(doc *Document) (string, bool) {
+	if c.stringKey() {
+		key, ok := doc.Fields[c.config.PrimaryKey].Value.(string)
+		return key, ok
+	}
+	key, err := c.primaryKey(doc)
+	return key, err == nil
+}
+
+// orderKey returns a string that sorts like the primary key of doc: the key
+// itself for a string key, otherwise the hex form of the index encoding of
+// the key fields, so that numbers sort by value. Stored documents cache it
+// in Document.order.
+func (c *CollectionImpl) orderKey(doc *Document) string {
+	if c.stringKey() {
+		key, _ := c.documentKey(doc)
+		return key
+	}
+	var encoded []byte
+	for _, name := range c.keyFields() {
+		value, _ := encodeIndexValue(doc.Fields[name])
+		encoded = append(encoded, value...)
+	}
+	return hex.EncodeToString(encoded)
+}
+
+// restoreOrderKeys caches the order key of documents loaded from a dump and
+// puts them in primary key order.
+func (c *CollectionImpl) restoreOrderKeys() {
+	c.ordered = docList{docs: make([]*Document, 0, len(c.documents)), unsorted: true}
+	for _, doc := range c.documents {
+		doc.order = c.orderKey(doc)
+		c.ordered.docs = append(c.ordered.docs, doc)
+	}
+	c.ordered.sort()
+}
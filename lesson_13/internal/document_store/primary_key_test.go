@@ -0,0 +1,185 @@
+package document_store
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func orderDoc(tenant string, n float64, item string) *Document {
+	return &Document{Fields: map[string]DocumentField{
+		"tenant": *str(tenant),
+		"n":      *number(n),
+		"item":   *str(item),
+	}}
+}
+
+func listKeys(t *testing.T, col *CollectionImpl, params ListParams) ([]string, string) {
+	t.Helper()
+	page, err := col.ListPage(params)
+	if err != nil {
+		t.Fatalf("unexpected error on ListPage: %v", err)
+	}
+	keys := make([]string, 0, len(page.Docs))
+	for _, doc := range page.Docs {
+		key, _ := col.documentKey(&doc)
+		keys = append(keys, key)
+	}
+	return keys, page.NextCursor
+}
+
+func TestEncodeKey(t *testing.T) {
+	tests := []struct {
+		values []any
+		want   string
+	}{
+		{[]any{"abc"}, "abc"},
+		{[]any{42}, "42"},
+		{[]any{int64(-7)}, "-7"},
+		{[]any{2.5}, "2.5"},
+		{[]any{1e21}, "1000000000000000000000"},
+		{[]any{math.Copysign(0, -1)}, "0"},
+		{[]any{"acme", 7}, `["acme",7]`},
+		{[]any{"a<b", 1.5}, `["a<b",1.5]`},
+		{[]any{*str("x"), *number(3)}, `["x",3]`},
+	}
+	for _, tt := range tests {
+		got, err := EncodeKey(tt.values...)
+		if err != nil {
+			t.Fatalf("unexpected error encoding %v: %v", tt.values, err)
+		}
+		if got != tt.want {
+			t.Fatalf("expected %q for %v, got %q", tt.want, tt.values, got)
+		}
+	}
+
+	for _, values := range [][]any{nil, {true}, {"a", nil}} {
+		if _, err := EncodeKey(values...); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("expected ErrInvalidKey for %v, got %v", values, err)
+		}
+	}
+}
+
+func TestNumericKey(t *testing.T) {
+	col, _ := NewStore().CreateCollection("items", &CollectionConfig{PrimaryKey: "id", NumericKey: true})
+	for _, id := range []float64{10, 2, -1, 42} {
+		key, err := col.Put(&Document{Fields: map[string]DocumentField{"id": *number(id)}})
+		if err != nil {
+			t.Fatalf("unexpected error on Put: %v", err)
+		}
+		if want, _ := EncodeKey(id); key != want {
+			t.Fatalf("expected key %q, got %q", want, key)
+		}
+	}
+
+	if _, err := col.Get("42"); err != nil {
+		t.Fatalf("unexpected error on Get: %v", err)
+	}
+	keys, _ := listKeys(t, col, ListParams{})
+	assertIDs(t, keys, "-1", "2", "10", "42")
+	keys, _ = listKeys(t, col, ListParams{Sort: []SortField{{Field: "id", Desc: true}}})
+	assertIDs(t, keys, "42", "10", "2", "-1")
+
+	// Pages resume after the cursor in numeric order.
+	keys, cursor := listKeys(t, col, ListParams{PageParams: PageParams{Limit: 2}})
+	assertIDs(t, keys, "-1", "2")
+	keys, _ = listKeys(t, col, ListParams{PageParams: PageParams{Limit: 2, Cursor: cursor}})
+	assertIDs(t, keys, "10", "42")
+
+	if err := col.Delete("2"); err != nil {
+		t.Fatalf("unexpected error on Delete: %v", err)
+	}
+	var fve *FieldValueError
+	if _, err := col.Put(userDoc("7", "Alice")); !errors.As(err, &fve) || fve.Field != "id" {
+		t.Fatalf("expected a FieldValueError for a string key, got %v", err)
+	}
+}
+
+func TestCompositeKey(t *testing.T) {
+	col, _ := NewStore().CreateCollection("orders", &CollectionConfig{KeyFields: []string{"tenant", "n"}})
+	col.CreateIndex("item")
+	for _, doc := range []*Document{
+		orderDoc("beta", 1, "pen"),
+		orderDoc("acme", 10, "pen"),
+		orderDoc("acme", 7, "ink"),
+	} {
+		if _, err := col.Put(doc); err != nil {
+			t.Fatalf("unexpected error on Put: %v", err)
+		}
+	}
+
+	key, _ := EncodeKey("acme", 7)
+	if key != `["acme",7]` {
+		t.Fatalf("expected the canonical key [\"acme\",7], got %q", key)
+	}
+	doc, err := col.Get(key)
+	if err != nil || doc.Fields["item"].Value != "ink" {
+		t.Fatalf("expected the ink order, got %v, %v", doc, err)
+	}
+	keys, _ := listKeys(t, col, ListParams{})
+	assertIDs(t, keys, `["acme",7]`, `["acme",10]`, `["beta",1]`)
+
+	// Ties in an index are broken by key value.
+	docs, _ := col.Query("item", QueryParams{MinValue: str("pen"), MaxValue: str("pen")})
+	if len(docs) != 2 || docs[0].Fields["tenant"].Value != "acme" {
+		t.Fatalf("expected the acme pen order first, got %v", docs)
+	}
+
+	// Projections keep every key field.
+	projected, _ := col.GetWithProjection(key, &Projection{Include: []string{"item"}})
+	if len(projected.Fields) != 3 {
+		t.Fatalf("expected the key fields and item, got %v", projected.Fields)
+	}
+
+	if err := col.Delete(key); err != nil {
+		t.Fatalf("unexpected error on Delete: %v", err)
+	}
+	if col.Exists(key) {
+		t.Fatalf("expected the order to be deleted")
+	}
+	var fve *FieldValueError
+	missing := &Document{Fields: map[string]DocumentField{"tenant": *str("acme")}}
+	if _, err := col.Put(missing); !errors.As(err, &fve) || fve.Field != "n" {
+		t.Fatalf("expected a FieldValueError for the missing key field, got %v", err)
+	}
+}
+
+func TestCompositeKey_DumpRoundTrip(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("orders", &CollectionConfig{KeyFields: []string{"tenant", "n"}})
+	col.Put(orderDoc("acme", 10, "pen"))
+	col.Put(orderDoc("acme", 7, "ink"))
+
+	dump, err := store.Dump()
+	if err != nil {
+		t.Fatalf("unexpected error on Dump: %v", err)
+	}
+	loaded, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error loading dump: %v", err)
+	}
+	restored, _ := loaded.GetCollection("orders")
+	assertIDs(t, documentKeys(restored), `["acme",10]`, `["acme",7]`)
+	keys, _ := listKeys(t, restored, ListParams{})
+	assertIDs(t, keys, `["acme",7]`, `["acme",10]`)
+	if _, err := restored.Put(orderDoc("acme", 7, "ink2")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	if len(restored.List()) != 2 {
+		t.Fatalf("expected the Put to replace the restored order, got %v", restored.List())
+	}
+}
+
+func TestCreateCollection_InvalidKeyConfig(t *testing.T) {
+	configs := []*CollectionConfig{
+		{PrimaryKey: "id", KeyFields: []string{"a", "b"}},
+		{KeyFields: []string{"a", "a"}},
+		{KeyFields: []string{"a", ""}},
+		{PrimaryKey: "id", NumericKey: true, GenerateKeys: true},
+	}
+	for i, config := range configs {
+		if _, err := NewStore().CreateCollection("c", config); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("config %d: expected ErrInvalidConfig, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package document_store
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidProjection = errors.New("invalid projection")
+
+// Projection selects the fields returned for each document: either only the
+// Include paths or everything but the Exclude paths. Paths may be dotted to
+// select inside nested objects. The primary key is always included.
+type Projection struct {
+	Include []string
+	Exclude []string
+}
+
+func (p *Projection) validate() error {
+	if p == nil {
+		return nil
+	}
+	if len(p.Include) > 0 && len(p.Exclude) > 0 {
+		return ErrInvalidProjection
+	}
+	for _, path := range append(p.Include, p.Exclude...) {
+		if path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") {
+			return ErrInvalidProjection
+		}
+	}
+	return nil
+}
+
+// apply returns a projected copy of doc; the stored document is not changed.
+func (p *Projection) apply(doc Document, keyFields []string) Document {
+	if p == nil || len(p.Include) == 0 && len(p.Exclude) == 0 {
+		return doc
+	}
+
+	if len(p.Include) > 0 {
+		fields := make(map[string]DocumentField)
+		for _, key := range keyFields {
+			if keyField, exists := doc.Fields[key]; exists {
+				fields[key] = keyField
+			}
+		}
+		for _, path := range p.Include {
+			includePath(fields, doc.Fields, path)
+		}
+		return Document{Fields: fields, Version: doc.Version, ExpiresAt: cloneTime(doc.ExpiresAt)}
+	}
+
+	fields := make(map[string]DocumentField, len(doc.Fields))
+	for name, field := range doc.Fields {
+		fields[name] = field
+	}
+	for _, path := range p.Exclude {
+		if !slices.Contains(keyFields, path) {
+			excludePath(fields, path)
+		}
+	}
+	return Document{Fields: fields, Version: doc.Version, ExpiresAt: cloneTime(doc.ExpiresAt)}
+}
+
+func includePath(dst, src map[string]DocumentField, path string) {
+	if field, exists := src[path]; exists {
+		dst[path] = field
+		return
+	}
+	name, rest, nested := strings.Cut(path, ".")
+	field, exists := src[name]
+	if !nested || !exists || field.Type != DocumentFieldTypeObject {
+		return
+	}
+	object, ok := objectEntries(field.Value)
+	if !ok {
+		return
+	}
+
+	var target map[string]any
+	if existing, exists := dst[name]; exists {
+		target, _ = existing.Value.(map[string]any)
+	}
+	if target == nil {
+		target = make(map[string]any)
+	}
+	if includeObjectPath(target, object, strings.Split(rest, ".")) {
+		dst[name] = DocumentField{Type: DocumentFieldTypeObject, Value: target}
+	}
+}
+
+func includeObjectPath(dst, src map[string]any, parts []string) bool {
+	value, exists := src[parts[0]]
+	if !exists {
+		return false
+	}
+	if len(parts) == 1 {
+		dst[parts[0]] = value
+		return true
+	}
+	object, ok := objectEntries(value)
+	if !ok {
+		return false
+	}
+	child, _ := dst[parts[0]].(map[string]any)
+	if child == nil {
+		child = make(map[string]any)
+	}
+	if !includeObjectPath(child, object, parts[1:]) {
+		return false
+	}
+	dst[parts[0]] = child
+	return true
+}
+
+func excludePath(fields map[string]DocumentField, path string) {
+	if _, exists := fields[path]; exists {
+		delete(fields, path)
+		return
+	}
+	name, rest, nested := strings.Cut(path, ".")
+	field, exists := fields[name]
+	if !nested || !exists || field.Type != DocumentFieldTypeObject {
+		return
+	}
+	if object, ok := excludeObjectPath(field.Value, strings.Split(rest, ".")); ok {
+		fields[name] = DocumentField{Type: DocumentFieldTypeObject, Value: object}
+	}
+}
+
+// excludeObjectPath returns a copy of the object without the path, copying
+// only the objects along the path.
+func excludeObjectPath(value any, parts []string) (map[string]any, bool) {
+	object, ok := objectEntries(value)
+	if !ok {
+		return nil, false
+	}
+	child, exists := object[parts[0]]
+	if !exists {
+		return nil, false
+	}
+
+	copied := make(map[string]any, len(object))
+	for k, v := range object {
+		copied[k] = v
+	}
+	if len(parts) == 1 {
+		delete(copied, parts[0])
+		return copied, true
+	}
+	nested, ok := excludeObjectPath(child, parts[1:])
+	if !ok {
+		return nil, false
+	}
+	copied[parts[0]] = nested
+	return copied, true
+}
+
+// objectEntries returns the entries of an object field value. Objects set
+// from Go may hold DocumentField values instead of plain ones.
+func objectEntries(value any) (map[string]any, bool) {
+	switch object := value.(type) {
+	case map[string]any:
+		return object, true
+	case map[string]DocumentField:
+		entries := make(map[string]any, len(object))
+		for k, v := range object {
+			entries[k] = v
+		}
+		return entries, true
+	case DocumentField:
+		if object.Type == DocumentFieldTypeObject {
+			return objectEntries(object.Value)
+		}
+	}
+	return nil, false
+}
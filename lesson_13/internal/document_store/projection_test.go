@@ -0,0 +1,124 @@
+package document_store
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newAddressCollection(t *testing.T) *CollectionImpl {
+	t.Helper()
+	col, err := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	if err != nil {
+		t.Fatalf("unexpected error creating collection: %v", err)
+	}
+	for _, id := range []string{"1", "2"} {
+		doc := addressDoc(id, map[string]any{
+			"city": "Kyiv",
+			"geo":  map[string]any{"lat": 50.45, "lng": 30.52},
+		})
+		doc.Fields["name"] = DocumentField{Type: DocumentFieldTypeString, Value: "user" + id}
+		if _, err := col.Put(doc); err != nil {
+			t.Fatalf("unexpected error on Put: %v", err)
+		}
+	}
+	return col
+}
+
+func TestGetWithProjection_Include(t *testing.T) {
+	col := newAddressCollection(t)
+
+	doc, err := col.GetWithProjection("1", &Projection{Include: []string{"name", "address.geo.lat"}})
+	if err != nil {
+		t.Fatalf("unexpected error on GetWithProjection: %v", err)
+	}
+	want := map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "1"},
+		"name": {Type: DocumentFieldTypeString, Value: "user1"},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]any{
+			"geo": map[string]any{"lat": 50.45},
+		}},
+	}
+	if !reflect.DeepEqual(doc.Fields, want) {
+		t.Fatalf("expected %v, got %v", want, doc.Fields)
+	}
+}
+
+func TestGetWithProjection_Exclude(t *testing.T) {
+	col := newAddressCollection(t)
+
+	doc, err := col.GetWithProjection("1", &Projection{Exclude: []string{"id", "name", "address.geo", "missing.path"}})
+	if err != nil {
+		t.Fatalf("unexpected error on GetWithProjection: %v", err)
+	}
+	want := map[string]DocumentField{
+		"id":      {Type: DocumentFieldTypeString, Value: "1"},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]any{"city": "Kyiv"}},
+	}
+	if !reflect.DeepEqual(doc.Fields, want) {
+		t.Fatalf("expected %v, got %v", want, doc.Fields)
+	}
+
+	stored, _ := col.Get("1")
+	if len(stored.Fields) != 3 {
+		t.Fatalf("expected stored document to keep all fields, got %v", stored.Fields)
+	}
+	if _, exists := lookupField(stored, "address.geo.lng"); !exists {
+		t.Fatalf("expected stored nested object to be unchanged, got %v", stored.Fields["address"])
+	}
+}
+
+func TestGetWithProjection_NilReturnsWholeDocument(t *testing.T) {
+	col := newAddressCollection(t)
+
+	doc, err := col.GetWithProjection("2", nil)
+	if err != nil {
+		t.Fatalf("unexpected error on GetWithProjection: %v", err)
+	}
+	if len(doc.Fields) != 3 {
+		t.Fatalf("expected all fields, got %v", doc.Fields)
+	}
+}
+
+func TestProjection_ListAndQuery(t *testing.T) {
+	col := newAddressCollection(t)
+	col.CreateIndex("name")
+	projection := &Projection{Include: []string{"address.city"}}
+
+	page, err := col.ListPage(ListParams{Projection: projection})
+	if err != nil {
+		t.Fatalf("unexpected error on ListPage: %v", err)
+	}
+	assertIDs(t, pageIDs(page), "1", "2")
+	for _, doc := range page.Docs {
+		if len(doc.Fields) != 2 || doc.Fields["name"].Type != "" {
+			t.Fatalf("expected only id and address, got %v", doc.Fields)
+		}
+	}
+
+	page, err = col.QueryPage("name", QueryParams{Desc: true, Projection: projection})
+	if err != nil {
+		t.Fatalf("unexpected error on QueryPage: %v", err)
+	}
+	assertIDs(t, pageIDs(page), "2", "1")
+	if city, _ := lookupField(&page.Docs[0], "address.city"); city.Value != "Kyiv" {
+		t.Fatalf("expected address.city to be kept, got %v", page.Docs[0].Fields)
+	}
+}
+
+func TestProjection_Invalid(t *testing.T) {
+	col := newAddressCollection(t)
+
+	invalid := []*Projection{
+		{Include: []string{"name"}, Exclude: []string{"address"}},
+		{Include: []string{""}},
+		{Exclude: []string{"address."}},
+	}
+	for _, projection := range invalid {
+		if _, err := col.GetWithProjection("1", projection); err != ErrInvalidProjection {
+			t.Fatalf("expected ErrInvalidProjection for %+v, got %v", projection, err)
+		}
+		if _, err := col.ListPage(ListParams{Projection: projection}); err != ErrInvalidProjection {
+			t.Fatalf("expected ErrInvalidProjection for %+v, got %v", projection, err)
+		}
+	}
+}
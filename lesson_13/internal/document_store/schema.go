@@ -0,0 +1,259 @@
+package document_store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidSchema = errors.New("invalid schema")
+var ErrSchemaViolation = errors.New("document does not match the collection schema")
+
+// Schema constrains the documents of a collection with a subset of JSON
+// Schema. The schema of a collection describes the document itself as an
+// object. Type is one of "string", "number", "integer", "boolean", "object"
+// or "array", and any type is allowed when it is empty. Minimum and Maximum
+// bound numbers; MinLength and MaxLength bound the length of strings and
+// arrays.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+// FieldError is a violation of the schema at Path, a dotted path such as
+// "address.city" with array elements written as "tags[1]".
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+// SchemaError lists every violation found in a document, ordered by path.
+type SchemaError struct {
+	Errors []FieldError
+}
+
+func (e *SchemaError) Error() string {
+	violations := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		violations[i] = fe.Path + ": " + fe.Message
+	}
+	return fmt.Sprintf("%v: %s", ErrSchemaViolation, strings.Join(violations, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+var schemaTypes = map[string]bool{
+	"": true, "string": true, "number": true, "integer": true, "boolean": true, "object": true, "array": true,
+}
+
+func (s *Schema) validate(path string) error {
+	if s == nil {
+		return nil
+	}
+	if !schemaTypes[s.Type] {
+		return fmt.Errorf("%w: %s: unknown type %q", ErrInvalidSchema, schemaPath(path), s.Type)
+	}
+	if s.Minimum != nil && s.Maximum != nil && *s.Minimum > *s.Maximum ||
+		s.MinLength != nil && s.MaxLength != nil && *s.MinLength > *s.MaxLength {
+		return fmt.Errorf("%w: %s: minimum above maximum", ErrInvalidSchema, schemaPath(path))
+	}
+	for _, name := range s.Required {
+		if name == "" {
+			return fmt.Errorf("%w: %s: empty required field", ErrInvalidSchema, schemaPath(path))
+		}
+	}
+	for name, property := range s.Properties {
+		if property == nil {
+			return fmt.Errorf("%w: %s: empty property schema", ErrInvalidSchema, schemaPath(joinPath(path, name)))
+		}
+		if err := property.validate(joinPath(path, name)); err != nil {
+			return err
+		}
+	}
+	return s.Items.validate(path + "[]")
+}
+
+// checkSchema must be called with the write lock held.
+func (c *CollectionImpl) checkSchema(doc *Document) error {
+	if c.config.Schema == nil {
+		return nil
+	}
+	var errs []FieldError
+	c.config.Schema.check("", doc.Fields, &errs)
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			return errs[i].Path < errs[j].Path
+		})
+		return &SchemaError{Errors: errs}
+	}
+	return nil
+}
+
+// check appends the violations of value to errs.
+func (s *Schema) check(path string, value any, errs *[]FieldError) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, FieldError{Path: schemaPath(path), Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Documents are normalized before they are checked, so only top-level
+	// values are wrapped in fields.
+	if field, ok := value.(DocumentField); ok {
+		value = field.Value
+	}
+	actual, ok := valueType(value)
+	if !ok {
+		fail("unsupported value %v", value)
+		return
+	}
+	if !s.allows(actual, value) {
+		fail("expected %s, got %s", s.Type, actual)
+		return
+	}
+
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		fail("must be one of %v", s.Enum)
+	}
+	switch actual {
+	case DocumentFieldTypeNumber:
+		n, _ := numberValue(value)
+		if s.Minimum != nil && n < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+	case DocumentFieldTypeString:
+		s.checkLength(utf8.RuneCountInString(value.(string)), fail)
+	case DocumentFieldTypeArray:
+		elements := arrayElements(value)
+		s.checkLength(len(elements), fail)
+		if s.Items != nil {
+			for i, element := range elements {
+				s.Items.check(path+"["+strconv.Itoa(i)+"]", element, errs)
+			}
+		}
+	case DocumentFieldTypeObject:
+		s.checkObject(path, objectFields(value), errs)
+	}
+}
+
+func (s *Schema) checkObject(path string, fields map[string]any, errs *[]FieldError) {
+	for _, name := range s.Required {
+		if _, exists := fields[name]; !exists {
+			*errs = append(*errs, FieldError{Path: joinPath(path, name), Message: "is required"})
+		}
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, known := s.Properties[name]
+		switch {
+		case known:
+			property.check(joinPath(path, name), fields[name], errs)
+		case s.AdditionalProperties != nil && !*s.AdditionalProperties:
+			*errs = append(*errs, FieldError{Path: joinPath(path, name), Message: "is not allowed"})
+		}
+	}
+}
+
+func (s *Schema) checkLength(n int, fail func(string, ...any)) {
+	if s.MinLength != nil && n < *s.MinLength {
+		fail("length must be >= %d", *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		fail("length must be <= %d", *s.MaxLength)
+	}
+}
+
+func (s *Schema) allows(actual DocumentFieldType, value any) bool {
+	switch s.Type {
+	case "":
+		return true
+	case "boolean":
+		return actual == DocumentFieldTypeBool
+	case "integer":
+		n, ok := numberValue(value)
+		return ok && n == math.Trunc(n)
+	}
+	return string(actual) == s.Type
+}
+
+func (s *Schema) inEnum(value any) bool {
+	n, isNumber := numberValue(value)
+	for _, allowed := range s.Enum {
+		if m, ok := numberValue(allowed); ok && isNumber {
+			if m == n {
+				return true
+			}
+		} else if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueType returns the field type of a value, which is a Go value or one
+// decoded from JSON.
+func valueType(value any) (DocumentFieldType, bool) {
+	switch value.(type) {
+	case string:
+		return DocumentFieldTypeString, true
+	case bool:
+		return DocumentFieldTypeBool, true
+	case map[string]any, map[string]DocumentField:
+		return DocumentFieldTypeObject, true
+	}
+	if _, ok := numberValue(value); ok {
+		return DocumentFieldTypeNumber, true
+	}
+	if value != nil && arrayElements(value) != nil {
+		return DocumentFieldTypeArray, true
+	}
+	return "", false
+}
+
+func objectFields(value any) map[string]any {
+	switch object := value.(type) {
+	case map[string]any:
+		return object
+	case map[string]DocumentField:
+		fields := make(map[string]any, len(object))
+		for name, field := range object {
+			fields[name] = field
+		}
+		return fields
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func schemaPath(path string) string {
+	if path == "" {
+		return "(document)"
+	}
+	return path
+}
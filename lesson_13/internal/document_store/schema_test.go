@@ -0,0 +1,139 @@
+package document_store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newSchemaCollection(t *testing.T, store *Store) *CollectionImpl {
+	t.Helper()
+	minAge, maxAge, maxTags := 0.0, 150.0, 2
+	closed := false
+	col, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: &Schema{
+		Type:     "object",
+		Required: []string{"id", "name"},
+		Properties: map[string]*Schema{
+			"id":   {Type: "string"},
+			"name": {Type: "string"},
+			"age":  {Type: "integer", Minimum: &minAge, Maximum: &maxAge},
+			"role": {Enum: []any{"admin", "user"}},
+			"tags": {Type: "array", MaxLength: &maxTags, Items: &Schema{Type: "string"}},
+			"address": {
+				Type:                 "object",
+				Required:             []string{"city"},
+				AdditionalProperties: &closed,
+				Properties:           map[string]*Schema{"city": {Type: "string"}},
+			},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error creating collection: %v", err)
+	}
+	return col
+}
+
+func schemaErrors(t *testing.T, err error) []FieldError {
+	t.Helper()
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("expected a SchemaError, got %v", err)
+	}
+	return schemaErr.Errors
+}
+
+func TestSchema_Put(t *testing.T) {
+	col := newSchemaCollection(t, NewStore())
+	valid := userDoc("1", "Alice")
+	valid.Fields["age"] = *number(30)
+	valid.Fields["tags"] = DocumentField{Type: DocumentFieldTypeArray, Value: []any{"a", "b"}}
+	valid.Fields["address"] = DocumentField{Type: DocumentFieldTypeObject, Value: map[string]any{"city": "Kyiv"}}
+	if _, err := col.Put(valid); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+
+	invalid := &Document{Fields: map[string]DocumentField{
+		"id":      {Type: DocumentFieldTypeString, Value: "2"},
+		"age":     {Type: DocumentFieldTypeString, Value: "30"},
+		"role":    {Type: DocumentFieldTypeString, Value: "root"},
+		"tags":    {Type: DocumentFieldTypeArray, Value: []any{"a", 1.0, "c"}},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]any{"zip": "01001"}},
+	}}
+	want := []FieldError{
+		{"address.city", "is required"},
+		{"address.zip", "is not allowed"},
+		{"age", "expected integer, got string"},
+		{"name", "is required"},
+		{"role", "must be one of [admin user]"},
+		{"tags", "length must be <= 2"},
+		{"tags[1]", "expected string, got number"},
+	}
+	_, err := col.Put(invalid)
+	if errs := schemaErrors(t, err); !reflect.DeepEqual(errs, want) {
+		t.Fatalf("expected %v, got %v", want, errs)
+	}
+	if col.Exists("2") {
+		t.Fatalf("expected the invalid document not to be stored")
+	}
+
+	tooOld := userDoc("3", "Carol")
+	tooOld.Fields["age"] = *number(150.5)
+	want = []FieldError{{"age", "expected integer, got number"}}
+	_, err = col.Put(tooOld)
+	if errs := schemaErrors(t, err); !reflect.DeepEqual(errs, want) {
+		t.Fatalf("expected %v, got %v", want, errs)
+	}
+}
+
+func TestSchema_UpdatesAndBatches(t *testing.T) {
+	store := NewStore()
+	col := newSchemaCollection(t, store)
+	col.Put(userDoc("1", "Alice"))
+
+	if _, err := col.Update("1", Patch{Inc: map[string]float64{"age": -1}}); err == nil {
+		t.Fatalf("expected Update below the minimum to fail")
+	}
+	if _, err := col.Update("1", Patch{Unset: []string{"name"}}); !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("expected ErrSchemaViolation, got %v", err)
+	}
+	if doc, _ := col.Get("1"); doc.Fields["name"].Value != "Alice" {
+		t.Fatalf("expected the document to be unchanged, got %v", doc)
+	}
+
+	tx := store.Begin()
+	tx.Put("users", &Document{Fields: map[string]DocumentField{"id": *str("2")}})
+	if err := tx.Commit(); !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("expected ErrSchemaViolation on Commit, got %v", err)
+	}
+
+	_, errs := col.PutMany([]*Document{userDoc("3", "Carol"), {Fields: map[string]DocumentField{"id": *str("4")}}})
+	if errs[0] != nil || !errors.Is(errs[1], ErrSchemaViolation) {
+		t.Fatalf("expected only the second document to fail, got %v", errs)
+	}
+}
+
+func TestSchema_InvalidSchema(t *testing.T) {
+	store := NewStore()
+	_, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: &Schema{
+		Properties: map[string]*Schema{"age": {Type: "int"}},
+	}})
+	if !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("expected ErrInvalidSchema, got %v", err)
+	}
+}
+
+func TestSchema_Dump(t *testing.T) {
+	store := NewStore()
+	newSchemaCollection(t, store)
+	dump, _ := store.Dump()
+	loaded, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error loading dump: %v", err)
+	}
+	col, _ := loaded.GetCollection("users")
+	doc := userDoc("1", "Alice")
+	doc.Fields["role"] = *str("guest")
+	if _, err := col.Put(doc); !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("expected the loaded schema to reject the document, got %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package document_store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".json"
+)
+
+var ErrNoDataDir = errors.New("store has no data directory")
+
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type SnapshotConfig struct {
+	Interval time.Duration
+	// Retain is the number of snapshot files kept on disk; at least one is.
+	Retain  int
+	Clock   Clock
+	OnError func(error)
+}
+
+// SnapshotManager periodically writes the store to a new snapshot file in the
+// store's data directory and drops the log records that the snapshot covers.
+type SnapshotManager struct {
+	store  *Store
+	dir    string
+	config SnapshotConfig
+
+	mu       sync.Mutex
+	lastLSN  uint64
+	lastTime time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewSnapshotManager(store *Store, config SnapshotConfig) (*SnapshotManager, error) {
+	if store.dir == "" {
+		return nil, ErrNoDataDir
+	}
+	if config.Retain < 1 {
+		config.Retain = 1
+	}
+	if config.Clock == nil {
+		config.Clock = realClock{}
+	}
+	return &SnapshotManager{
+		store:  store,
+		dir:    store.dir,
+		config: config,
+	}, nil
+}
+
+// Snapshot writes a snapshot now unless nothing changed since the last one and
+// returns the path of the newest snapshot file.
+func (m *SnapshotManager) Snapshot() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dump, lsn, err := m.store.dump()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(m.dir, snapshotName(lsn))
+	if lsn == m.lastLSN && !m.lastTime.IsZero() {
+		return path, nil
+	}
+
+	if err := writeFileAtomic(path, dump); err != nil {
+		return "", err
+	}
+	if err := m.store.wal.truncate(lsn); err != nil {
+		return "", err
+	}
+	if err := m.prune(); err != nil {
+		return "", err
+	}
+
+	m.lastLSN = lsn
+	m.lastTime = m.config.Clock.Now()
+	return path, nil
+}
+
+func (m *SnapshotManager) LastSnapshotTime() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastTime
+}
+
+// Start runs Snapshot every Interval until Stop is called.
+func (m *SnapshotManager) Start() {
+	if m.config.Interval <= 0 || m.stop != nil {
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run()
+}
+
+func (m *SnapshotManager) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+}
+
+func (m *SnapshotManager) run() {
+	defer close(m.done)
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-m.config.Clock.After(m.config.Interval):
+			if _, err := m.Snapshot(); err != nil && m.config.OnError != nil {
+				m.config.OnError(err)
+			}
+		}
+	}
+}
+
+func (m *SnapshotManager) prune() error {
+	names, err := listSnapshots(m.dir)
+	if err != nil {
+		return err
+	}
+	for len(names) > m.config.Retain {
+		if err := os.Remove(filepath.Join(m.dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+func snapshotName(lsn uint64) string {
+	return fmt.Sprintf("%s%020d%s", snapshotPrefix, lsn, snapshotSuffix)
+}
+
+// listSnapshots returns snapshot file names from oldest to newest.
+func listSnapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, snapshotPrefix) && strings.HasSuffix(name, snapshotSuffix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func latestSnapshot(dir string) (string, error) {
+	names, err := listSnapshots(dir)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+	return filepath.Join(dir, names[len(names)-1]), nil
+}
+
+// writeFileAtomic writes data to a temporary file, fsyncs it and renames it
+// over filename, so readers see either the old or the new content in full.
+func writeFileAtomic(filename string, data []byte) error {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
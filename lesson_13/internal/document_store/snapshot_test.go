@@ -0,0 +1,211 @@
+package document_store
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	added   chan struct{}
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		added: make(chan struct{}, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	c.added <- struct{}{}
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.at.After(c.now) {
+			w.ch <- c.now
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+}
+
+// waitForWaiter blocks until someone calls After.
+func (c *fakeClock) waitForWaiter(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for clock waiter")
+	}
+}
+
+func snapshotFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	names, err := listSnapshots(dir)
+	if err != nil {
+		t.Fatalf("unexpected error listing snapshots: %v", err)
+	}
+	return names
+}
+
+func TestSnapshotManager_TruncatesWAL(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStoreFromWAL(dir)
+	defer store.Close()
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(userDoc("1", "Alice"))
+	col.Put(userDoc("2", "Bob"))
+
+	manager, err := NewSnapshotManager(store, SnapshotConfig{Retain: 2})
+	if err != nil {
+		t.Fatalf("unexpected error creating snapshot manager: %v", err)
+	}
+	path, err := manager.Snapshot()
+	if err != nil {
+		t.Fatalf("unexpected error on Snapshot: %v", err)
+	}
+	if filepath.Base(path) != snapshotName(3) {
+		t.Fatalf("expected snapshot at lsn 3, got %s", path)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	if err != nil {
+		t.Fatalf("unexpected error on stat: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("expected empty wal after snapshot, got %d bytes", info.Size())
+	}
+
+	col.Put(userDoc("3", "Charlie"))
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	col, _ = reopened.GetCollection("users")
+	if len(col.List()) != 3 {
+		t.Fatalf("expected 3 documents, got %d", len(col.List()))
+	}
+	if _, err := col.Put(userDoc("4", "Dave")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	if pos := reopened.wal.position(); pos != 5 {
+		t.Fatalf("expected lsn to continue from 5, got %d", pos)
+	}
+}
+
+func TestSnapshotManager_Retention(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStoreFromWAL(dir)
+	defer store.Close()
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	manager, _ := NewSnapshotManager(store, SnapshotConfig{Retain: 2})
+
+	for i, name := range []string{"Alice", "Bob", "Charlie", "Dave"} {
+		col.Put(userDoc(string(rune('1'+i)), name))
+		if _, err := manager.Snapshot(); err != nil {
+			t.Fatalf("unexpected error on Snapshot: %v", err)
+		}
+	}
+
+	names := snapshotFiles(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("expected 2 snapshots to be retained, got %v", names)
+	}
+	if names[1] != snapshotName(5) {
+		t.Fatalf("expected newest snapshot at lsn 5, got %v", names)
+	}
+
+	// Nothing changed, so no new snapshot is written.
+	manager.Snapshot()
+	if got := snapshotFiles(t, dir); len(got) != 2 || got[1] != names[1] {
+		t.Fatalf("expected snapshots to be unchanged, got %v", got)
+	}
+}
+
+func TestSnapshotManager_Periodic(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStoreFromWAL(dir)
+	defer store.Close()
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(userDoc("1", "Alice"))
+
+	clock := newFakeClock()
+	manager, _ := NewSnapshotManager(store, SnapshotConfig{
+		Interval: time.Minute,
+		Retain:   3,
+		Clock:    clock,
+	})
+	manager.Start()
+	defer manager.Stop()
+
+	clock.waitForWaiter(t)
+	clock.Advance(30 * time.Second)
+	if names := snapshotFiles(t, dir); len(names) != 0 {
+		t.Fatalf("expected no snapshot before the interval, got %v", names)
+	}
+
+	clock.Advance(30 * time.Second)
+	// The loop asks for the next tick only after the snapshot is written.
+	clock.waitForWaiter(t)
+	if names := snapshotFiles(t, dir); len(names) != 1 {
+		t.Fatalf("expected one snapshot after the interval, got %v", names)
+	}
+	if !manager.LastSnapshotTime().Equal(clock.Now()) {
+		t.Fatalf("expected snapshot time %v, got %v", clock.Now(), manager.LastSnapshotTime())
+	}
+}
+
+func TestNewSnapshotManager_RequiresDataDir(t *testing.T) {
+	if _, err := NewSnapshotManager(NewStore(), SnapshotConfig{}); err != ErrNoDataDir {
+		t.Fatalf("expected ErrNoDataDir, got %v", err)
+	}
+}
+
+func TestDumpToFile_LeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore()
+	store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+
+	filename := filepath.Join(dir, "dump.json")
+	if err := store.DumpToFile(filename); err != nil {
+		t.Fatalf("unexpected error on DumpToFile: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "dump.json" {
+		t.Fatalf("expected only dump.json in dir, got %v", entries)
+	}
+	if _, err := NewStoreFromFile(filename); err != nil {
+		t.Fatalf("unexpected error loading dump: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package document_store
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"sync"
 )
@@ -13,23 +14,40 @@ var ErrCollectionNotFound = errors.New("collection not found")
 var ErrUnsupportedDocumentField = errors.New("unsupported document field")
 
 type Store struct {
+	// commitMu is held for reading by every mutation and for writing by Dump,
+	// so a dump always matches a single position in the write-ahead log.
+	commitMu    sync.RWMutex
 	mu          sync.RWMutex
 	collections map[string]*CollectionImpl
+	wal         *writeAheadLog
+	dir         string
+	clock       Clock
+	// replaying is set while the write-ahead log is replayed.
+	replaying  bool
+	reaperStop chan struct{}
+	reaperDone chan struct{}
 }
 
 type collectionDump struct {
-	Config     CollectionConfig     `json:"config"`
-	Documents  map[string]*Document `json:"documents"`
-	IndexNames []string             `json:"index_names"`
+	Config    CollectionConfig        `json:"config"`
+	Documents map[string]*Document    `json:"documents"`
+	Indexes   map[string]IndexOptions `json:"indexes,omitempty"`
+	Version   uint64                  `json:"version,omitempty"`
+	// Order lists the keys of a capped collection, oldest inserted first.
+	Order []string `json:"order,omitempty"`
+	// IndexNames is only read, from dumps made before Indexes existed.
+	IndexNames []string `json:"index_names,omitempty"`
 }
 
 type storeDump struct {
+	LSN         uint64                    `json:"lsn,omitempty"`
 	Collections map[string]collectionDump `json:"collections"`
 }
 
 func NewStore() *Store {
 	return &Store{
 		collections: make(map[string]*CollectionImpl),
+		clock:       realClock{},
 	}
 }
 
@@ -37,12 +55,22 @@ func (s *Store) CreateCollection(name string, config *CollectionConfig) (*Collec
 	if config == nil {
 		return nil, ErrUnsupportedDocumentField
 	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	s.commitMu.RLock()
+	defer s.commitMu.RUnlock()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if _, exists := s.collections[name]; exists {
 		return nil, ErrCollectionAlreadyExists
 	}
+	if err := s.wal.append(walRecord{Op: walOpCreateCollection, Collection: name, Config: config}); err != nil {
+		return nil, err
+	}
 	s.collections[name] = &CollectionImpl{
+		name:      name,
+		store:     s,
 		documents: make(map[string]*Document),
 		config:    *config,
 		indexes:   make(map[string]*index),
@@ -61,11 +89,23 @@ func (s *Store) GetCollection(name string) (*CollectionImpl, error) {
 }
 
 func (s *Store) DeleteCollection(name string) error {
+	s.commitMu.RLock()
+	defer s.commitMu.RUnlock()
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, exists := s.collections[name]; !exists {
+	collection, exists := s.collections[name]
+	if !exists {
 		return ErrCollectionNotFound
 	}
+	if err := s.wal.append(walRecord{Op: walOpDeleteCollection, Collection: name}); err != nil {
+		return err
+	}
+
+	// Callers may still hold the collection; its writes must not reach the log.
+	collection.mu.Lock()
+	collection.dropped = true
+	collection.endSubscriptions(ErrCollectionNotFound)
+	collection.mu.Unlock()
 
 	delete(s.collections, name)
 	return nil
@@ -82,56 +122,86 @@ func (s *Store) ListCollections() []string {
 }
 
 func NewStoreFromDump(dump []byte) (*Store, error) {
+	store, _, err := newStoreFromDump(dump)
+	return store, err
+}
+
+func newStoreFromDump(dump []byte) (*Store, uint64, error) {
 	var dumpData storeDump
 
 	if err := json.Unmarshal(dump, &dumpData); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	store := &Store{
-		collections: make(map[string]*CollectionImpl),
-	}
+	store := NewStore()
 
 	for name, collData := range dumpData.Collections {
+		if collData.Documents == nil {
+			collData.Documents = make(map[string]*Document)
+		}
 		collection := &CollectionImpl{
+			name:      name,
+			store:     store,
 			documents: collData.Documents,
 			config:    collData.Config,
 			indexes:   make(map[string]*index),
 		}
+		collection.restoreOrderKeys()
+		collection.restoreVersions(collData.Version)
+		collection.restoreOrder(collData.Order)
 
 		for _, indexName := range collData.IndexNames {
-			collection.CreateIndex(indexName)
+			if err := collection.CreateIndex(indexName); err != nil {
+				return nil, 0, fmt.Errorf("collection %q: index %q: %w", name, indexName, err)
+			}
+		}
+		for indexName, options := range collData.Indexes {
+			if err := collection.CreateIndex(indexName, options); err != nil {
+				return nil, 0, fmt.Errorf("collection %q: index %q: %w", name, indexName, err)
+			}
 		}
 
 		store.collections[name] = collection
 	}
 
-	return store, nil
+	return store, dumpData.LSN, nil
 }
 
 func (s *Store) Dump() ([]byte, error) {
+	dump, _, err := s.dump()
+	return dump, err
+}
+
+// dump also returns the log position the dump reflects.
+func (s *Store) dump() ([]byte, uint64, error) {
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	dumpData := storeDump{
+		LSN:         s.wal.position(),
 		Collections: make(map[string]collectionDump),
 	}
 
 	for name, collection := range s.collections {
 		collection.mu.RLock()
-		indexNames := make([]string, 0, len(collection.indexes))
-		for indexName := range collection.indexes {
-			indexNames = append(indexNames, indexName)
+		indexes := make(map[string]IndexOptions, len(collection.indexes))
+		for indexName, idx := range collection.indexes {
+			indexes[indexName] = idx.options
 		}
 
 		dumpData.Collections[name] = collectionDump{
-			Config:     collection.config,
-			Documents:  collection.documents,
-			IndexNames: indexNames,
+			Config:    collection.config,
+			Documents: collection.documents,
+			Indexes:   indexes,
+			Version:   collection.version,
+			Order:     collection.dumpOrder(),
 		}
 		collection.mu.RUnlock()
 	}
 
-	return json.Marshal(dumpData)
+	dump, err := json.Marshal(dumpData)
+	return dump, dumpData.LSN, err
 }
 
 func NewStoreFromFile(filename string) (*Store, error) {
@@ -149,5 +219,5 @@ func (s *Store) DumpToFile(filename string) error {
 		return err
 	}
 
-	return os.WriteFile(filename, dump, 0644)
+	return writeFileAtomic(filename, dump)
 }
@@ -0,0 +1,163 @@
+package document_store
+
+import (
+	"sort"
+	"time"
+)
+
+// SetClock replaces the clock that decides when documents expire. It must be
+// called before the store is used.
+func (s *Store) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// ReapExpired deletes the expired documents of every collection, emitting
+// the events of Delete, and returns how many it deleted. Until then expired
+// documents are only hidden from reads.
+func (s *Store) ReapExpired() (int, error) {
+	s.mu.RLock()
+	collections := make([]*CollectionImpl, 0, len(s.collections))
+	for _, c := range s.collections {
+		collections = append(collections, c)
+	}
+	s.mu.RUnlock()
+
+	total := 0
+	for _, c := range collections {
+		n, err := c.reapExpired()
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// StartReaper runs ReapExpired every interval until StopReaper is called.
+func (s *Store) StartReaper(interval time.Duration, onError func(error)) {
+	if interval <= 0 || s.reaperStop != nil {
+		return
+	}
+	s.reaperStop = make(chan struct{})
+	s.reaperDone = make(chan struct{})
+	go s.runReaper(interval, onError)
+}
+
+func (s *Store) StopReaper() {
+	if s.reaperStop == nil {
+		return
+	}
+	close(s.reaperStop)
+	<-s.reaperDone
+	s.reaperStop = nil
+}
+
+func (s *Store) runReaper(interval time.Duration, onError func(error)) {
+	defer close(s.reaperDone)
+	for {
+		select {
+		case <-s.reaperStop:
+			return
+		case <-s.clock.After(interval):
+			if _, err := s.ReapExpired(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// reapExpired deletes the expired documents under a single log record.
+func (c *CollectionImpl) reapExpired() (int, error) {
+	if !c.expires() {
+		return 0, nil
+	}
+	c.lockWrite()
+	defer c.unlockWrite()
+	if c.dropped {
+		return 0, nil
+	}
+
+	var keys []string
+	for key, doc := range c.documents {
+		if c.expired(doc) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	sort.Strings(keys)
+	records := make([]walRecord, len(keys))
+	for i, key := range keys {
+		records[i] = walRecord{Op: walOpDelete, Collection: c.name, Key: key}
+	}
+	if err := c.log(walRecord{Op: walOpTx, Ops: records}); err != nil {
+		return 0, err
+	}
+
+	c.beginBatch()
+	for _, key := range keys {
+		doc := c.documents[key]
+		c.setDocument(key, nil)
+		c.notify(key, doc, nil)
+	}
+	c.endBatch()
+	return len(keys), nil
+}
+
+func (c *CollectionImpl) expires() bool {
+	return c.config.TTL > 0 || c.config.TTLField != ""
+}
+
+// expiresAt returns when doc expires under the collection's TTL settings, or
+// nil if it never does. A replayed document keeps the expiry it was logged
+// with, since it was written at an earlier time.
+func (c *CollectionImpl) expiresAt(doc *Document) *time.Time {
+	if c.replaying() {
+		return doc.ExpiresAt
+	}
+	var from time.Time
+	switch {
+	case c.config.TTLField != "":
+		field, exists := lookupField(doc, c.config.TTLField)
+		if !exists || field.Type != DocumentFieldTypeNumber {
+			return nil
+		}
+		seconds, ok := numberValue(field.Value)
+		if !ok {
+			return nil
+		}
+		from = time.Unix(0, int64(seconds*float64(time.Second)))
+	case c.config.TTL > 0:
+		from = c.now()
+	default:
+		return nil
+	}
+	at := from.Add(c.config.TTL).UTC()
+	return &at
+}
+
+// expired reports whether doc has expired. Nothing expires while the log is
+// replayed, so that replayed deletes still find their documents.
+func (c *CollectionImpl) expired(doc *Document) bool {
+	return doc.ExpiresAt != nil && !c.replaying() && !c.now().Before(*doc.ExpiresAt)
+}
+
+// live returns doc, or nil if it is nil or has expired.
+func (c *CollectionImpl) live(doc *Document) *Document {
+	if doc == nil || c.expired(doc) {
+		return nil
+	}
+	return doc
+}
+
+func (c *CollectionImpl) now() time.Time {
+	if c.store == nil {
+		return time.Now()
+	}
+	return c.store.clock.Now()
+}
+
+func (c *CollectionImpl) replaying() bool {
+	return c.store != nil && c.store.replaying
+}
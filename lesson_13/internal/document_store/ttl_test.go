@@ -0,0 +1,266 @@
+package document_store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newSessionStore(t *testing.T, dir string, config CollectionConfig) (*Store, *CollectionImpl, *fakeClock) {
+	t.Helper()
+	store := NewStore()
+	if dir != "" {
+		var err error
+		if store, err = NewStoreFromWAL(dir); err != nil {
+			t.Fatalf("unexpected error opening store: %v", err)
+		}
+	}
+	clock := newFakeClock()
+	store.SetClock(clock)
+	config.PrimaryKey = "id"
+	col, err := store.CreateCollection("sessions", &config)
+	if err != nil {
+		t.Fatalf("unexpected error creating collection: %v", err)
+	}
+	return store, col, clock
+}
+
+func TestTTL_ExpiredDocumentsAreHidden(t *testing.T) {
+	_, col, clock := newSessionStore(t, "", CollectionConfig{TTL: time.Minute})
+	col.CreateIndex("name")
+	col.Put(userDoc("1", "Alice"))
+	clock.Advance(30 * time.Second)
+	col.Put(userDoc("2", "Bob"))
+
+	clock.Advance(30 * time.Second)
+	if _, err := col.Get("1"); err != ErrDocumentNotFound {
+		t.Fatalf("expected ErrDocumentNotFound for an expired document, got %v", err)
+	}
+	if col.Exists("1") || !col.Exists("2") {
+		t.Fatalf("expected only document 2 to exist")
+	}
+	if docs := col.List(); len(docs) != 1 || docs[0].Fields["id"].Value != "2" {
+		t.Fatalf("expected List to return document 2, got %v", docs)
+	}
+	if docs, _ := col.Query("name", QueryParams{}); len(docs) != 1 {
+		t.Fatalf("expected Query to return 1 document, got %d", len(docs))
+	}
+	if docs, _ := col.Find(Filter{Op: FilterOpEq, Field: "name", Value: str("Alice")}); len(docs) != 0 {
+		t.Fatalf("expected Find to return no documents, got %v", docs)
+	}
+	if n, _ := col.Count(Filter{Op: FilterOpAnd}); n != 1 {
+		t.Fatalf("expected Count 1, got %d", n)
+	}
+	if err := col.Delete("1"); err != ErrDocumentNotFound {
+		t.Fatalf("expected ErrDocumentNotFound on Delete, got %v", err)
+	}
+	if _, err := col.Update("1", Patch{Set: map[string]DocumentField{"name": *str("Al")}}); err != ErrDocumentNotFound {
+		t.Fatalf("expected ErrDocumentNotFound on Update, got %v", err)
+	}
+}
+
+func TestTTL_WriteRenewsExpiry(t *testing.T) {
+	_, col, clock := newSessionStore(t, "", CollectionConfig{TTL: time.Minute})
+	sub, _ := col.Watch(WatchOptions{})
+	defer sub.Close()
+
+	doc := userDoc("1", "Alice")
+	col.Put(doc)
+	if want := clock.Now().Add(time.Minute); doc.ExpiresAt == nil || !doc.ExpiresAt.Equal(want) {
+		t.Fatalf("expected ExpiresAt %v, got %v", want, doc.ExpiresAt)
+	}
+	clock.Advance(50 * time.Second)
+	col.Update("1", Patch{Set: map[string]DocumentField{"name": *str("Alicia")}})
+	clock.Advance(50 * time.Second)
+	if _, err := col.Get("1"); err != nil {
+		t.Fatalf("expected the updated document to live on, got %v", err)
+	}
+
+	// Storing over an expired document inserts a new one.
+	clock.Advance(time.Minute)
+	col.Put(userDoc("1", "Bob"))
+	nextEvent(t, sub)
+	nextEvent(t, sub)
+	if event := nextEvent(t, sub); event.Type != ChangeInsert {
+		t.Fatalf("expected an insert over the expired document, got %+v", event)
+	}
+}
+
+func TestTTL_ExpiryField(t *testing.T) {
+	_, col, clock := newSessionStore(t, "", CollectionConfig{TTLField: "expires"})
+	expiring := userDoc("1", "Alice")
+	expiring.Fields["expires"] = *number(float64(clock.Now().Add(time.Hour).Unix()))
+	col.Put(expiring)
+	col.Put(userDoc("2", "Bob"))
+
+	clock.Advance(time.Hour)
+	if col.Exists("1") {
+		t.Fatalf("expected document 1 to expire at its expiry field")
+	}
+	clock.Advance(24 * time.Hour)
+	if !col.Exists("2") {
+		t.Fatalf("expected a document without the expiry field to never expire")
+	}
+}
+
+func TestTTL_UniqueIndexIgnoresExpired(t *testing.T) {
+	_, col, clock := newSessionStore(t, "", CollectionConfig{TTL: time.Minute})
+	col.CreateIndex("name", IndexOptions{Unique: true})
+	col.Put(userDoc("1", "Alice"))
+	clock.Advance(time.Minute)
+	if _, err := col.Put(userDoc("2", "Alice")); err != nil {
+		t.Fatalf("expected the expired document not to hold its unique value, got %v", err)
+	}
+}
+
+func TestTTL_UniqueIndexIgnoresExpiredAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, col, clock := newSessionStore(t, dir, CollectionConfig{TTL: time.Minute})
+	col.CreateIndex("name", IndexOptions{Unique: true})
+	col.Put(userDoc("1", "Alice"))
+	clock.Advance(2 * time.Minute)
+	if _, err := col.Put(userDoc("2", "Alice")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+
+	dump, err := store.Dump()
+	if err != nil {
+		t.Fatalf("unexpected error on Dump: %v", err)
+	}
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	reopened.SetClock(clock)
+	sessions, _ := reopened.GetCollection("sessions")
+	if sessions.Exists("1") || !sessions.Exists("2") {
+		t.Fatalf("expected only document 2 to be live after replay")
+	}
+	if _, err := sessions.Put(userDoc("3", "Alice")); !errors.Is(err, ErrUniqueConstraintViolation) {
+		t.Fatalf("expected the replayed unique index to hold document 2, got %v", err)
+	}
+
+	// The dump is restored with the real clock, long after both writes.
+	loaded, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error loading dump: %v", err)
+	}
+	restored, _ := loaded.GetCollection("sessions")
+	if len(restored.indexes) != 1 {
+		t.Fatalf("expected the unique index to be restored, got %d indexes", len(restored.indexes))
+	}
+}
+
+func TestTTL_ReapExpired(t *testing.T) {
+	store, col, clock := newSessionStore(t, "", CollectionConfig{TTL: time.Minute})
+	col.CreateIndex("name")
+	col.Put(userDoc("1", "Alice"))
+	col.Put(userDoc("2", "Bob"))
+	clock.Advance(30 * time.Second)
+	col.Put(userDoc("3", "Carol"))
+	sub, _ := col.Watch(WatchOptions{})
+	defer sub.Close()
+
+	clock.Advance(30 * time.Second)
+	n, err := store.ReapExpired()
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 documents reaped, got %d, %v", n, err)
+	}
+	for _, key := range []string{"1", "2"} {
+		event := nextEvent(t, sub)
+		if event.Type != ChangeDelete || event.Key != key || event.OldDoc == nil {
+			t.Fatalf("expected delete of %s, got %+v", key, event)
+		}
+	}
+	assertNoEvent(t, sub)
+	if len(col.documents) != 1 || len(col.indexes["name"].keys) != 1 {
+		t.Fatalf("expected one document left in the collection and index, got %d and %d",
+			len(col.documents), len(col.indexes["name"].keys))
+	}
+}
+
+func TestTTL_Reaper(t *testing.T) {
+	store, col, clock := newSessionStore(t, "", CollectionConfig{TTL: time.Minute})
+	col.Put(userDoc("1", "Alice"))
+	sub, _ := col.Watch(WatchOptions{})
+	defer sub.Close()
+
+	store.StartReaper(10*time.Second, func(err error) {
+		t.Errorf("unexpected reaper error: %v", err)
+	})
+	defer store.StopReaper()
+	for range 6 {
+		clock.waitForWaiter(t)
+		clock.Advance(10 * time.Second)
+	}
+
+	select {
+	case event := <-sub.Events():
+		if event.Type != ChangeDelete || event.Key != "1" {
+			t.Fatalf("expected delete of 1, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the reaper")
+	}
+}
+
+func TestTTL_CloseStopsReaper(t *testing.T) {
+	store, _, _ := newSessionStore(t, t.TempDir(), CollectionConfig{TTL: time.Minute})
+	store.StartReaper(10*time.Second, nil)
+	done := store.reaperDone
+	if err := store.Close(); err != nil {
+		t.Fatalf("unexpected error on Close: %v", err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatal("expected Close to stop the reaper")
+	}
+}
+
+func TestTTL_ProjectionKeepsExpiry(t *testing.T) {
+	_, col, _ := newSessionStore(t, "", CollectionConfig{TTL: time.Minute})
+	col.Put(userDoc("1", "Alice"))
+	want, _ := col.Get("1")
+	for _, projection := range []*Projection{{Include: []string{"name"}}, {Exclude: []string{"name"}}} {
+		doc, err := col.GetWithProjection("1", projection)
+		if err != nil {
+			t.Fatalf("unexpected error on GetWithProjection: %v", err)
+		}
+		if doc.ExpiresAt == nil || !doc.ExpiresAt.Equal(*want.ExpiresAt) {
+			t.Fatalf("expected the projected document to expire at %v, got %v", want.ExpiresAt, doc.ExpiresAt)
+		}
+	}
+}
+
+func TestTTL_ExpiryIsKeptOnReplay(t *testing.T) {
+	dir := t.TempDir()
+	store, col, clock := newSessionStore(t, dir, CollectionConfig{TTL: time.Minute})
+	col.Put(userDoc("1", "Alice"))
+	col.Put(userDoc("2", "Bob"))
+	clock.Advance(time.Minute)
+	col.Put(userDoc("3", "Carol"))
+	if n, err := store.ReapExpired(); err != nil || n != 2 {
+		t.Fatalf("expected 2 documents reaped, got %d, %v", n, err)
+	}
+	col.Put(userDoc("4", "Dave"))
+	clock.Advance(time.Minute)
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	reopened.SetClock(clock)
+	sessions, _ := reopened.GetCollection("sessions")
+	if len(sessions.documents) != 2 {
+		t.Fatalf("expected 2 documents after replay, got %d", len(sessions.documents))
+	}
+	if sessions.Exists("3") || sessions.Exists("4") {
+		t.Fatalf("expected the replayed documents to keep their logged expiry")
+	}
+}
@@ -0,0 +1,244 @@
+package document_store
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrTxDone = errors.New("transaction already committed or rolled back")
+
+// Tx buffers writes to several collections and applies all of them or none
+// on Commit. Reads do not see the buffered writes, and the conditions of
+// PutIfVersion and DeleteIfVersion are checked on Commit. A Tx must not be
+// used from several goroutines at once.
+type Tx struct {
+	store *Store
+	ops   []txOp
+	done  bool
+}
+
+type txOp struct {
+	collection *CollectionImpl
+	key        string
+	doc        *Document
+	patch      *Patch
+	delete     bool
+	version    *uint64
+	// source is the caller's document, which gets the version doc is
+	// stored with.
+	source *Document
+}
+
+// txUndo restores a document and the collection version changed by a
+// transaction that fails to commit.
+type txUndo struct {
+	collection *CollectionImpl
+	key        string
+	doc        *Document
+	version    uint64
+	// seq is the document's place in the insertion order of a capped
+	// collection.
+	seq uint64
+}
+
+func (c *CollectionImpl) undoFor(key string) txUndo {
+	return txUndo{collection: c, key: key, doc: c.documents[key], version: c.version, seq: c.capEntries[key].seq}
+}
+
+func (u *txUndo) restore() {
+	c := u.collection
+	c.setDocument(u.key, u.doc)
+	c.version = u.version
+	if u.doc != nil && u.seq != 0 {
+		c.restoreInsertion(u.key, u.seq)
+	}
+}
+
+func (s *Store) Begin() *Tx {
+	return &Tx{store: s}
+}
+
+// Put returns the key doc will be stored under, generating it if needed.
+func (tx *Tx) Put(collection string, doc *Document) (string, error) {
+	return tx.put(collection, doc, nil)
+}
+
+func (tx *Tx) PutIfVersion(collection string, doc *Document, version uint64) (string, error) {
+	return tx.put(collection, doc, &version)
+}
+
+func (tx *Tx) put(collection string, doc *Document, version *uint64) (string, error) {
+	c, err := tx.collection(collection)
+	if err != nil {
+		return "", err
+	}
+	prepared, key, err := c.prepare(doc)
+	if err != nil {
+		return "", err
+	}
+	tx.ops = append(tx.ops, txOp{collection: c, key: key, doc: prepared, source: doc, version: version})
+	return key, nil
+}
+
+func (tx *Tx) Delete(collection, key string) error {
+	return tx.delete(collection, key, nil)
+}
+
+func (tx *Tx) DeleteIfVersion(collection, key string, version uint64) error {
+	return tx.delete(collection, key, &version)
+}
+
+func (tx *Tx) delete(collection, key string, version *uint64) error {
+	c, err := tx.collection(collection)
+	if err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, txOp{collection: c, key: key, delete: true, version: version})
+	return nil
+}
+
+func (tx *Tx) Update(collection, key string, patch Patch) error {
+	c, err := tx.collection(collection)
+	if err != nil {
+		return err
+	}
+	if err := patch.validate(c.keyFields()); err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, txOp{collection: c, key: key, patch: &patch})
+	return nil
+}
+
+func (tx *Tx) collection(name string) (*CollectionImpl, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	return tx.store.GetCollection(name)
+}
+
+// Commit applies the buffered writes in order while holding the locks of
+// every collection they touch, and logs them as a single record. If any write
+// fails, the ones already applied are undone and its error is returned.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	s := tx.store
+	s.commitMu.RLock()
+	defer s.commitMu.RUnlock()
+
+	// Collections are locked in name order, so transactions sharing
+	// collections cannot deadlock.
+	var collections []*CollectionImpl
+	locked := make(map[*CollectionImpl]bool)
+	for _, op := range tx.ops {
+		if !locked[op.collection] {
+			locked[op.collection] = true
+			collections = append(collections, op.collection)
+		}
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].name < collections[j].name
+	})
+	for _, c := range collections {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	for _, c := range collections {
+		if c.dropped {
+			return ErrCollectionNotFound
+		}
+	}
+
+	undo := make([]txUndo, 0, len(tx.ops))
+	records := make([]walRecord, 0, len(tx.ops))
+	var err error
+	for _, op := range tx.ops {
+		c := op.collection
+		undo = append(undo, c.undoFor(op.key))
+		var rec walRecord
+		if rec, err = op.apply(); err != nil {
+			break
+		}
+		records = append(records, rec)
+	}
+	rec := walRecord{Op: walOpTx, Ops: records}
+	if err == nil {
+		err = s.wal.append(rec)
+	}
+	if err != nil {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i].restore()
+		}
+		return err
+	}
+
+	for i, op := range tx.ops {
+		if op.source != nil {
+			op.source.written(records[i].Doc)
+		}
+		op.collection.notify(op.key, op.collection.live(undo[i].doc), records[i].Doc)
+	}
+	for _, c := range collections {
+		c.evict(rec.writtenKeys(c.name)...)
+	}
+	return nil
+}
+
+// Rollback discards the buffered writes.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.ops = nil
+	return nil
+}
+
+// apply performs the write and returns its log record. Must be called with
+// the write lock of the collection held.
+func (op *txOp) apply() (walRecord, error) {
+	c := op.collection
+	stored := c.live(c.documents[op.key])
+	exists := stored != nil
+	if op.version != nil {
+		var current uint64
+		if exists {
+			current = stored.Version
+		}
+		if current != *op.version {
+			return walRecord{}, ErrVersionConflict
+		}
+	}
+
+	if op.delete {
+		if !exists {
+			return walRecord{}, ErrDocumentNotFound
+		}
+		c.setDocument(op.key, nil)
+		return walRecord{Op: walOpDelete, Collection: c.name, Key: op.key}, nil
+	}
+
+	doc := &Document{}
+	if op.patch != nil {
+		var err error
+		if doc, err = c.patched(op.key, op.patch); err != nil {
+			return walRecord{}, err
+		}
+	} else {
+		doc.Fields = op.doc.Fields
+	}
+	if err := c.checkWrite(op.key, doc); err != nil {
+		return walRecord{}, err
+	}
+	c.version++
+	doc.Version = c.version
+	doc.ExpiresAt = c.expiresAt(doc)
+	c.setDocument(op.key, doc)
+	return walRecord{Op: walOpPut, Collection: c.name, Key: op.key, Doc: doc}, nil
+}
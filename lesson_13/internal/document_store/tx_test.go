@@ -0,0 +1,203 @@
+package document_store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func newQueueStore(t *testing.T, dir string) (*Store, *CollectionImpl, *CollectionImpl) {
+	t.Helper()
+	store := NewStore()
+	if dir != "" {
+		var err error
+		if store, err = NewStoreFromWAL(dir); err != nil {
+			t.Fatalf("unexpected error opening store: %v", err)
+		}
+	}
+	pending, _ := store.CreateCollection("pending", &CollectionConfig{PrimaryKey: "id"})
+	processed, _ := store.CreateCollection("processed", &CollectionConfig{PrimaryKey: "id"})
+	pending.Put(userDoc("1", "job1"))
+	pending.Put(userDoc("2", "job2"))
+	return store, pending, processed
+}
+
+func TestTx_CommitMovesDocuments(t *testing.T) {
+	store, pending, processed := newQueueStore(t, "")
+	processed.CreateIndex("name")
+
+	tx := store.Begin()
+	doc, _ := pending.Get("1")
+	if err := tx.Delete("pending", "1"); err != nil {
+		t.Fatalf("unexpected error on Delete: %v", err)
+	}
+	moved := userDoc("1", doc.Fields["name"].Value.(string))
+	if _, err := tx.Put("processed", moved); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	if err := tx.Update("pending", "2", Patch{Set: map[string]DocumentField{"name": *str("job2b")}}); err != nil {
+		t.Fatalf("unexpected error on Update: %v", err)
+	}
+	if !pending.Exists("1") || processed.Exists("1") {
+		t.Fatalf("expected buffered writes not to be visible before Commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("unexpected error on Commit: %v", err)
+	}
+	if pending.Exists("1") || !processed.Exists("1") {
+		t.Fatalf("expected document 1 to be moved to processed")
+	}
+	assertIDs(t, queryIDs(t, processed, "name", QueryParams{}), "1")
+	if moved.Version != 1 {
+		t.Fatalf("expected the moved document to get version 1, got %d", moved.Version)
+	}
+	updated, _ := pending.Get("2")
+	if updated.Fields["name"].Value != "job2b" || updated.Version != 3 {
+		t.Fatalf("expected updated document at version 3, got %v", updated)
+	}
+
+	if err := tx.Commit(); err != ErrTxDone {
+		t.Fatalf("expected ErrTxDone, got %v", err)
+	}
+	if _, err := tx.Put("pending", userDoc("3", "job3")); err != ErrTxDone {
+		t.Fatalf("expected ErrTxDone, got %v", err)
+	}
+}
+
+func TestTx_FailedCommitUndoesWrites(t *testing.T) {
+	store, pending, processed := newQueueStore(t, "")
+	processed.CreateIndex("name", IndexOptions{Fields: []string{"name"}, Unique: true})
+	processed.Put(userDoc("9", "taken"))
+
+	tests := []struct {
+		name string
+		last func(tx *Tx) error
+		want error
+	}{
+		{"missing document", func(tx *Tx) error { return tx.Delete("pending", "missing") }, ErrDocumentNotFound},
+		{"unique violation", func(tx *Tx) error {
+			_, err := tx.Put("processed", userDoc("3", "taken"))
+			return err
+		}, ErrUniqueConstraintViolation},
+		{"version conflict", func(tx *Tx) error { return tx.DeleteIfVersion("pending", "2", 1) }, ErrVersionConflict},
+	}
+	for _, tt := range tests {
+		tx := store.Begin()
+		tx.Delete("pending", "1")
+		tx.Put("processed", userDoc("1", "job1"))
+		tx.Put("pending", userDoc("2", "replaced"))
+		if err := tt.last(tx); err != nil {
+			t.Fatalf("%s: unexpected error buffering write: %v", tt.name, err)
+		}
+		if err := tx.Commit(); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+
+		if !pending.Exists("1") || processed.Exists("1") {
+			t.Fatalf("%s: expected document 1 to stay in pending", tt.name)
+		}
+		doc, _ := pending.Get("2")
+		if doc.Fields["name"].Value != "job2" || doc.Version != 2 {
+			t.Fatalf("%s: expected document 2 to be restored, got %v", tt.name, doc)
+		}
+		assertIDs(t, queryIDs(t, processed, "name", QueryParams{}), "9")
+	}
+
+	pending.Put(userDoc("3", "job3"))
+	if v := getVersion(t, pending, "3"); v != 3 {
+		t.Fatalf("expected failed commits not to use up versions, got %d", v)
+	}
+}
+
+func TestTx_Rollback(t *testing.T) {
+	store, pending, _ := newQueueStore(t, "")
+
+	tx := store.Begin()
+	tx.Delete("pending", "1")
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("unexpected error on Rollback: %v", err)
+	}
+	if err := tx.Commit(); err != ErrTxDone {
+		t.Fatalf("expected ErrTxDone, got %v", err)
+	}
+	if !pending.Exists("1") {
+		t.Fatalf("expected rolled back delete not to be applied")
+	}
+}
+
+func TestTx_UnknownCollection(t *testing.T) {
+	store, _, _ := newQueueStore(t, "")
+
+	tx := store.Begin()
+	if _, err := tx.Put("missing", userDoc("1", "x")); err != ErrCollectionNotFound {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+	tx.Delete("pending", "1")
+	store.DeleteCollection("pending")
+	if err := tx.Commit(); err != ErrCollectionNotFound {
+		t.Fatalf("expected ErrCollectionNotFound for a dropped collection, got %v", err)
+	}
+}
+
+func TestTx_ReplayedFromWAL(t *testing.T) {
+	dir := t.TempDir()
+	store, _, _ := newQueueStore(t, dir)
+
+	tx := store.Begin()
+	tx.Delete("pending", "1")
+	tx.Put("processed", userDoc("1", "job1"))
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("unexpected error on Commit: %v", err)
+	}
+	failed := store.Begin()
+	failed.Delete("pending", "2")
+	failed.Delete("pending", "missing")
+	failed.Commit()
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	pending, _ := reopened.GetCollection("pending")
+	processed, _ := reopened.GetCollection("processed")
+	if pending.Exists("1") || !pending.Exists("2") || !processed.Exists("1") {
+		t.Fatalf("expected only the committed transaction after replay")
+	}
+}
+
+func TestTx_ConcurrentTransfersKeepDocumentsOnce(t *testing.T) {
+	store := NewStore()
+	a, _ := store.CreateCollection("a", &CollectionConfig{PrimaryKey: "id"})
+	b, _ := store.CreateCollection("b", &CollectionConfig{PrimaryKey: "id"})
+	for i := 0; i < 10; i++ {
+		a.Put(userDoc(fmt.Sprint(i), "doc"))
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprint((w + i) % 10)
+				from, to := "a", "b"
+				if b.Exists(key) {
+					from, to = "b", "a"
+				}
+				tx := store.Begin()
+				tx.Delete(from, key)
+				tx.Put(to, userDoc(key, "doc"))
+				tx.Commit()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if total := len(a.List()) + len(b.List()); total != 10 {
+		t.Fatalf("expected 10 documents across both collections, got %d", total)
+	}
+}
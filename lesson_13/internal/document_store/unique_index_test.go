@@ -0,0 +1,112 @@
+package document_store
+
+import (
+	"errors"
+	"testing"
+)
+
+func emailDoc(id, email string) *Document {
+	return &Document{Fields: map[string]DocumentField{
+		"id":    {Type: DocumentFieldTypeString, Value: id},
+		"email": {Type: DocumentFieldTypeString, Value: email},
+	}}
+}
+
+func TestUniqueIndex_RejectsConflictingPut(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	if err := col.CreateIndex("email", IndexOptions{Unique: true}); err != nil {
+		t.Fatalf("unexpected error on CreateIndex: %v", err)
+	}
+	if _, err := col.Put(emailDoc("1", "a@example.com")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+
+	_, err := col.Put(emailDoc("2", "a@example.com"))
+	if !errors.Is(err, ErrUniqueConstraintViolation) {
+		t.Fatalf("expected ErrUniqueConstraintViolation, got %v", err)
+	}
+	var uniqueErr *UniqueConstraintError
+	if !errors.As(err, &uniqueErr) || uniqueErr.Index != "email" {
+		t.Fatalf("expected UniqueConstraintError for index email, got %v", err)
+	}
+	if _, err := col.Get("2"); err != ErrDocumentNotFound {
+		t.Fatalf("expected rejected document not to be stored, got %v", err)
+	}
+	docs, _ := col.Query("email", QueryParams{})
+	if len(docs) != 1 {
+		t.Fatalf("expected index to be unchanged, got %d entries", len(docs))
+	}
+
+	// Replacing a document with its own value is not a conflict.
+	if _, err := col.Put(emailDoc("1", "a@example.com")); err != nil {
+		t.Fatalf("unexpected error replacing document: %v", err)
+	}
+	// Once the value is released it can be taken by another document.
+	if _, err := col.Put(emailDoc("1", "b@example.com")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	if _, err := col.Put(emailDoc("2", "a@example.com")); err != nil {
+		t.Fatalf("expected released value to be accepted, got %v", err)
+	}
+}
+
+func TestUniqueIndex_CreateOverDuplicatesFails(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(emailDoc("1", "a@example.com"))
+	col.Put(emailDoc("2", "b@example.com"))
+	col.Put(emailDoc("3", "a@example.com"))
+	col.Put(emailDoc("4", "b@example.com"))
+	col.Put(emailDoc("5", "c@example.com"))
+
+	err := col.CreateIndex("email", IndexOptions{Unique: true})
+	var uniqueErr *UniqueConstraintError
+	if !errors.As(err, &uniqueErr) {
+		t.Fatalf("expected UniqueConstraintError, got %v", err)
+	}
+	if len(uniqueErr.Conflicts) != 2 {
+		t.Fatalf("expected 2 conflicting groups, got %v", uniqueErr.Conflicts)
+	}
+	first, second := uniqueErr.Conflicts[0], uniqueErr.Conflicts[1]
+	if first[0] != "1" || first[1] != "3" || second[0] != "2" || second[1] != "4" {
+		t.Fatalf("unexpected conflicts: %v", uniqueErr.Conflicts)
+	}
+	if _, err := col.Query("email", QueryParams{}); err != ErrIndexNotFound {
+		t.Fatalf("expected index not to be created, got %v", err)
+	}
+}
+
+func TestUniqueIndex_Compound(t *testing.T) {
+	col, _ := NewStore().CreateCollection("members", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("org_email", IndexOptions{Fields: []string{"org", "email"}, Unique: true})
+
+	member := func(id, org, email string) *Document {
+		doc := emailDoc(id, email)
+		doc.Fields["org"] = DocumentField{Type: DocumentFieldTypeString, Value: org}
+		return doc
+	}
+	if _, err := col.Put(member("1", "acme", "a@example.com")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	if _, err := col.Put(member("2", "globex", "a@example.com")); err != nil {
+		t.Fatalf("expected same email in another org to be accepted, got %v", err)
+	}
+	if _, err := col.Put(member("3", "acme", "a@example.com")); !errors.Is(err, ErrUniqueConstraintViolation) {
+		t.Fatalf("expected ErrUniqueConstraintViolation, got %v", err)
+	}
+}
+
+func TestUniqueIndex_SurvivesDump(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.CreateIndex("email", IndexOptions{Unique: true})
+	col.Put(emailDoc("1", "a@example.com"))
+
+	dump, _ := col.store.Dump()
+	restored, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error on NewStoreFromDump: %v", err)
+	}
+	restoredCol, _ := restored.GetCollection("users")
+	if _, err := restoredCol.Put(emailDoc("2", "a@example.com")); !errors.Is(err, ErrUniqueConstraintViolation) {
+		t.Fatalf("expected unique index to be restored, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package document_store
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrVersionConflict = errors.New("version conflict")
+
+// PutIfVersion stores doc only if the stored document with its key has the
+// given version or, with version 0, if no document has its key. Like Put,
+// it returns the key.
+func (c *CollectionImpl) PutIfVersion(doc *Document, version uint64) (string, error) {
+	prepared, key, err := c.prepare(doc)
+	if err != nil {
+		return "", err
+	}
+	c.lockWrite()
+	defer c.unlockWrite()
+	var current uint64
+	if stored := c.live(c.documents[key]); stored != nil {
+		current = stored.Version
+	}
+	if current != version {
+		return "", ErrVersionConflict
+	}
+	if err := c.put(key, prepared); err != nil {
+		return "", err
+	}
+	doc.written(prepared)
+	return key, nil
+}
+
+// DeleteIfVersion deletes the document stored under key only if it has the
+// given version.
+func (c *CollectionImpl) DeleteIfVersion(key string, version uint64) error {
+	c.lockWrite()
+	defer c.unlockWrite()
+	doc := c.live(c.documents[key])
+	if doc == nil {
+		return ErrDocumentNotFound
+	}
+	if doc.Version != version {
+		return ErrVersionConflict
+	}
+	return c.delete(key)
+}
+
+// restoreVersions continues the versions of a loaded collection after the
+// dumped last version. Documents dumped before versions existed get new ones,
+// in primary key order.
+func (c *CollectionImpl) restoreVersions(version uint64) {
+	c.version = version
+	var unversioned []string
+	for key, doc := range c.documents {
+		if doc.Version == 0 {
+			unversioned = append(unversioned, key)
+		}
+		c.version = max(c.version, doc.Version)
+	}
+	sort.Strings(unversioned)
+	for _, key := range unversioned {
+		c.version++
+		c.documents[key].Version = c.version
+	}
+}
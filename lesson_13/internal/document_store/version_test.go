@@ -0,0 +1,142 @@
+package document_store
+
+import (
+	"testing"
+)
+
+func getVersion(t *testing.T, col Collection, key string) uint64 {
+	t.Helper()
+	doc, err := col.Get(key)
+	if err != nil {
+		t.Fatalf("unexpected error on Get(%q): %v", key, err)
+	}
+	return doc.Version
+}
+
+func TestVersion_IncreasesOnEveryWrite(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+
+	doc := userDoc("1", "Alice")
+	col.Put(doc)
+	if doc.Version != 1 || getVersion(t, col, "1") != 1 {
+		t.Fatalf("expected version 1, got %d", doc.Version)
+	}
+	col.Put(userDoc("2", "Bob"))
+	col.Put(userDoc("1", "Alicia"))
+	if v := getVersion(t, col, "1"); v != 3 {
+		t.Fatalf("expected version 3, got %d", v)
+	}
+	updated, _ := col.Update("1", Patch{Set: map[string]DocumentField{"name": *str("Al")}})
+	if updated.Version != 4 {
+		t.Fatalf("expected version 4 after Update, got %d", updated.Version)
+	}
+
+	col.Delete("1")
+	col.Put(userDoc("1", "Alice"))
+	if v := getVersion(t, col, "1"); v != 5 {
+		t.Fatalf("expected a recreated document to get version 5, got %d", v)
+	}
+
+	col.CreateIndex("name")
+	docs, _ := col.Query("name", QueryParams{MinValue: str("Bob"), MaxValue: str("Bob")})
+	if len(docs) != 1 || docs[0].Version != 2 {
+		t.Fatalf("expected Query to return version 2, got %+v", docs)
+	}
+}
+
+func TestPutIfVersion(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+
+	if _, err := col.PutIfVersion(userDoc("1", "Alice"), 0); err != nil {
+		t.Fatalf("unexpected error creating with version 0: %v", err)
+	}
+	if _, err := col.PutIfVersion(userDoc("1", "Again"), 0); err != ErrVersionConflict {
+		t.Fatalf("expected ErrVersionConflict creating an existing document, got %v", err)
+	}
+
+	// Two clients read version 1; only the first write wins.
+	if _, err := col.PutIfVersion(userDoc("1", "First"), 1); err != nil {
+		t.Fatalf("unexpected error on PutIfVersion: %v", err)
+	}
+	if _, err := col.PutIfVersion(userDoc("1", "Second"), 1); err != ErrVersionConflict {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	doc, _ := col.Get("1")
+	if doc.Fields["name"].Value != "First" || doc.Version != 2 {
+		t.Fatalf("expected first write at version 2, got %v", doc)
+	}
+}
+
+func TestDeleteIfVersion(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(userDoc("1", "Alice"))
+	col.Put(userDoc("1", "Alicia"))
+
+	if err := col.DeleteIfVersion("1", 1); err != ErrVersionConflict {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if err := col.DeleteIfVersion("1", 2); err != nil {
+		t.Fatalf("unexpected error on DeleteIfVersion: %v", err)
+	}
+	if err := col.DeleteIfVersion("1", 2); err != ErrDocumentNotFound {
+		t.Fatalf("expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestVersion_SurvivesDumpAndWAL(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStoreFromWAL(dir)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(userDoc("1", "Alice"))
+	col.Put(userDoc("2", "Bob"))
+	col.Delete("2")
+
+	dump, _ := store.Dump()
+	restored, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error on NewStoreFromDump: %v", err)
+	}
+	restoredCol, _ := restored.GetCollection("users")
+	if v := getVersion(t, restoredCol, "1"); v != 1 {
+		t.Fatalf("expected version 1 after restore, got %d", v)
+	}
+	restoredCol.Put(userDoc("2", "Bob"))
+	if v := getVersion(t, restoredCol, "2"); v != 3 {
+		t.Fatalf("expected versions to continue after the deleted document's, got %d", v)
+	}
+
+	store.Close()
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	reopenedCol, _ := reopened.GetCollection("users")
+	if v := getVersion(t, reopenedCol, "1"); v != 1 {
+		t.Fatalf("expected version 1 after replay, got %d", v)
+	}
+	if _, err := reopenedCol.PutIfVersion(userDoc("1", "Alicia"), 1); err != nil {
+		t.Fatalf("unexpected error on PutIfVersion after replay: %v", err)
+	}
+	if v := getVersion(t, reopenedCol, "1"); v != 3 {
+		t.Fatalf("expected version 3 after replay, got %d", v)
+	}
+}
+
+func TestVersion_AssignedToLegacyDump(t *testing.T) {
+	dump := []byte(`{"collections":{"users":{"config":{"PrimaryKey":"id"},"documents":{
+		"b":{"Fields":{"id":{"Type":"string","Value":"b"}}},
+		"a":{"Fields":{"id":{"Type":"string","Value":"a"}}}
+	}}}}`)
+	store, err := NewStoreFromDump(dump)
+	if err != nil {
+		t.Fatalf("unexpected error on NewStoreFromDump: %v", err)
+	}
+	col, _ := store.GetCollection("users")
+	if a, b := getVersion(t, col, "a"), getVersion(t, col, "b"); a != 1 || b != 2 {
+		t.Fatalf("expected versions 1 and 2, got %d and %d", a, b)
+	}
+	if _, err := col.PutIfVersion(userDoc("a", "Alice"), 0); err != ErrVersionConflict {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+}
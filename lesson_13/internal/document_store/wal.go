@@ -0,0 +1,333 @@
+package document_store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const walFileName = "wal.log"
+
+var ErrCorruptedWAL = errors.New("corrupted write-ahead log")
+var ErrStoreClosed = errors.New("store is closed")
+
+type walOp string
+
+const (
+	walOpPut              walOp = "put"
+	walOpDelete           walOp = "delete"
+	walOpCreateIndex      walOp = "create_index"
+	walOpDeleteIndex      walOp = "delete_index"
+	walOpCreateCollection walOp = "create_collection"
+	walOpDeleteCollection walOp = "delete_collection"
+	walOpTx               walOp = "tx"
+)
+
+type walRecord struct {
+	LSN        uint64            `json:"lsn"`
+	Op         walOp             `json:"op"`
+	Collection string            `json:"collection"`
+	Key        string            `json:"key,omitempty"`
+	Doc        *Document         `json:"doc,omitempty"`
+	FieldName  string            `json:"field_name,omitempty"`
+	Index      *IndexOptions     `json:"index,omitempty"`
+	Config     *CollectionConfig `json:"config,omitempty"`
+	// Ops holds the puts and deletes of a transaction or a batch, which are
+	// replayed together since the record is written at once.
+	Ops []walRecord `json:"ops,omitempty"`
+}
+
+// writtenKeys returns the keys of the documents rec stores in collection.
+func (rec walRecord) writtenKeys(collection string) []string {
+	var keys []string
+	switch {
+	case rec.Op == walOpTx:
+		for _, op := range rec.Ops {
+			keys = append(keys, op.writtenKeys(collection)...)
+		}
+	case rec.Op == walOpPut && rec.Collection == collection:
+		keys = append(keys, rec.Key)
+	}
+	return keys
+}
+
+// writeAheadLog is an append-only file of JSON records, one per line.
+// Every record is fsynced before the mutation it describes is applied.
+// A store without a log, made by NewStore, has a nil log that accepts every
+// record without writing it.
+type writeAheadLog struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	lsn    uint64
+	closed bool
+}
+
+func openWAL(path string) (*writeAheadLog, []walRecord, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, validSize, err := readWAL(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	// Drop a torn tail left by a crash in the middle of an append.
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	w := &writeAheadLog{path: path, file: file}
+	if len(records) > 0 {
+		w.lsn = records[len(records)-1].LSN
+	}
+	return w, records, nil
+}
+
+func readWAL(r io.Reader) ([]walRecord, int64, error) {
+	var records []walRecord
+	var validSize int64
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// An unterminated last line is an incomplete append.
+			return records, validSize, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &rec); err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				return records, validSize, nil
+			}
+			return nil, 0, fmt.Errorf("%w: record after lsn %d: %v", ErrCorruptedWAL, lastLSN(records), err)
+		}
+		records = append(records, rec)
+		validSize += int64(len(line))
+	}
+}
+
+func lastLSN(records []walRecord) uint64 {
+	if len(records) == 0 {
+		return 0
+	}
+	return records[len(records)-1].LSN
+}
+
+func (w *writeAheadLog) append(rec walRecord) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrStoreClosed
+	}
+
+	rec.LSN = w.lsn + 1
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if _, err := w.file.Write(data); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.lsn = rec.LSN
+	return nil
+}
+
+// truncate drops every record up to and including lsn, which a snapshot
+// already covers.
+func (w *writeAheadLog) truncate(lsn uint64) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrStoreClosed
+	}
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	records, _, err := readWAL(w.file)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range records {
+		if rec.LSN <= lsn {
+			continue
+		}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if err := writeFileAtomic(w.path, buf.Bytes()); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file = file
+	return nil
+}
+
+func (w *writeAheadLog) position() uint64 {
+	if w == nil {
+		return 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lsn
+}
+
+func (w *writeAheadLog) close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.file.Close()
+}
+
+// NewStoreFromWAL opens the store kept in dir: it loads the newest snapshot
+// file if there is one and replays every logged mutation that is newer than it.
+// All further changes are appended to the log until Close is called.
+func NewStoreFromWAL(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	store := NewStore()
+	var snapshotLSN uint64
+	snapshot, err := latestSnapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != "" {
+		dump, err := os.ReadFile(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		if store, snapshotLSN, err = newStoreFromDump(dump); err != nil {
+			return nil, fmt.Errorf("load %s: %w", snapshot, err)
+		}
+	}
+
+	wal, records, err := openWAL(filepath.Join(dir, walFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	store.replaying = true
+	for _, rec := range records {
+		if rec.LSN <= snapshotLSN {
+			continue
+		}
+		if err := store.replay(rec); err != nil {
+			wal.close()
+			return nil, fmt.Errorf("replay lsn %d: %w", rec.LSN, err)
+		}
+		// Capped collections evict once a whole record is applied, as the
+		// write that logged it did.
+		for name, collection := range store.collections {
+			collection.mu.Lock()
+			collection.evict(rec.writtenKeys(name)...)
+			collection.mu.Unlock()
+		}
+	}
+	store.replaying = false
+
+	if wal.lsn < snapshotLSN {
+		wal.lsn = snapshotLSN
+	}
+	store.wal = wal
+	store.dir = dir
+	return store, nil
+}
+
+func (s *Store) replay(rec walRecord) error {
+	switch rec.Op {
+	case walOpCreateCollection:
+		if rec.Config == nil {
+			return ErrCorruptedWAL
+		}
+		_, err := s.CreateCollection(rec.Collection, rec.Config)
+		return err
+	case walOpDeleteCollection:
+		return s.DeleteCollection(rec.Collection)
+	case walOpTx:
+		for _, op := range rec.Ops {
+			if err := s.replay(op); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	collection, err := s.GetCollection(rec.Collection)
+	if err != nil {
+		return err
+	}
+	switch rec.Op {
+	case walOpPut:
+		_, err := collection.Put(rec.Doc)
+		return err
+	case walOpDelete:
+		return collection.Delete(rec.Key)
+	case walOpCreateIndex:
+		if rec.Index == nil {
+			return collection.CreateIndex(rec.FieldName)
+		}
+		return collection.CreateIndex(rec.FieldName, *rec.Index)
+	case walOpDeleteIndex:
+		return collection.DeleteIndex(rec.FieldName)
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrCorruptedWAL, rec.Op)
+	}
+}
+
+// Close stops the reaper and closes the log. Writes after Close fail with
+// ErrStoreClosed.
+func (s *Store) Close() error {
+	s.StopReaper()
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+	return s.wal.close()
+}
@@ -0,0 +1,222 @@
+package document_store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func userDoc(id, name string) *Document {
+	return &Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: id},
+		"name": {Type: DocumentFieldTypeString, Value: name},
+	}}
+}
+
+func TestNewStoreFromWAL_ReplaysMutations(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	users, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	if err != nil {
+		t.Fatalf("unexpected error creating collection: %v", err)
+	}
+	if _, err := store.CreateCollection("tmp", &CollectionConfig{PrimaryKey: "id"}); err != nil {
+		t.Fatalf("unexpected error creating collection: %v", err)
+	}
+	for _, doc := range []*Document{userDoc("1", "Alice"), userDoc("2", "Bob"), userDoc("3", "Charlie")} {
+		if _, err := users.Put(doc); err != nil {
+			t.Fatalf("unexpected error on Put: %v", err)
+		}
+	}
+	if _, err := users.Put(userDoc("2", "Bobby")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	if err := users.Delete("3"); err != nil {
+		t.Fatalf("unexpected error on Delete: %v", err)
+	}
+	if err := users.CreateIndex("name"); err != nil {
+		t.Fatalf("unexpected error on CreateIndex: %v", err)
+	}
+	if err := users.CreateIndex("id"); err != nil {
+		t.Fatalf("unexpected error on CreateIndex: %v", err)
+	}
+	if err := users.DeleteIndex("id"); err != nil {
+		t.Fatalf("unexpected error on DeleteIndex: %v", err)
+	}
+	if err := store.DeleteCollection("tmp"); err != nil {
+		t.Fatalf("unexpected error on DeleteCollection: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("unexpected error on Close: %v", err)
+	}
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.GetCollection("tmp"); err != ErrCollectionNotFound {
+		t.Fatalf("expected deleted collection to stay deleted, got %v", err)
+	}
+	col, err := reopened.GetCollection("users")
+	if err != nil {
+		t.Fatalf("expected users collection after replay, got %v", err)
+	}
+	if len(col.List()) != 2 {
+		t.Fatalf("expected 2 documents after replay, got %d", len(col.List()))
+	}
+	doc, err := col.Get("2")
+	if err != nil {
+		t.Fatalf("expected document 2 after replay, got %v", err)
+	}
+	if doc.Fields["name"].Value != "Bobby" {
+		t.Fatalf("expected replayed overwrite, got %v", doc.Fields["name"].Value)
+	}
+	if _, err := col.Get("3"); err != ErrDocumentNotFound {
+		t.Fatalf("expected deleted document to stay deleted, got %v", err)
+	}
+	results, err := col.Query("name", QueryParams{})
+	if err != nil {
+		t.Fatalf("expected name index after replay, got %v", err)
+	}
+	if len(results) != 2 || results[0].Fields["name"].Value != "Alice" {
+		t.Fatalf("unexpected query results after replay: %+v", results)
+	}
+	if _, err := col.Query("id", QueryParams{}); err != ErrIndexNotFound {
+		t.Fatalf("expected deleted index to stay deleted, got %v", err)
+	}
+}
+
+func TestNewStoreFromWAL_IgnoresTornTail(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	if _, err := col.Put(userDoc("1", "Alice")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	store.Close()
+
+	// Simulate a crash halfway through writing the next record.
+	f, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("unexpected error opening wal: %v", err)
+	}
+	f.WriteString(`{"lsn":3,"op":"put","collection":"us`)
+	f.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("expected torn tail to be ignored, got %v", err)
+	}
+	col, _ = reopened.GetCollection("users")
+	if _, err := col.Put(userDoc("2", "Bob")); err != nil {
+		t.Fatalf("unexpected error on Put after recovery: %v", err)
+	}
+	reopened.Close()
+
+	again, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer again.Close()
+	col, _ = again.GetCollection("users")
+	if len(col.List()) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(col.List()))
+	}
+}
+
+func TestNewStoreFromWAL_ReplaysOnTopOfSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(userDoc("1", "Alice"))
+	manager, err := NewSnapshotManager(store, SnapshotConfig{Retain: 1})
+	if err != nil {
+		t.Fatalf("unexpected error creating snapshot manager: %v", err)
+	}
+	if _, err := manager.Snapshot(); err != nil {
+		t.Fatalf("unexpected error on Snapshot: %v", err)
+	}
+	col.Put(userDoc("2", "Bob"))
+	col.Delete("1")
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	col, err = reopened.GetCollection("users")
+	if err != nil {
+		t.Fatalf("expected users collection, got %v", err)
+	}
+	if _, err := col.Get("1"); err != ErrDocumentNotFound {
+		t.Fatalf("expected document 1 to be deleted, got %v", err)
+	}
+	if _, err := col.Get("2"); err != nil {
+		t.Fatalf("expected document 2, got %v", err)
+	}
+}
+
+func TestDeletedCollection_WritesAreNotLogged(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _ := NewStoreFromWAL(dir)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	store.DeleteCollection("users")
+	col.Put(userDoc("1", "Alice"))
+	store.Close()
+
+	reopened, err := NewStoreFromWAL(dir)
+	if err != nil {
+		t.Fatalf("expected replay to succeed, got %v", err)
+	}
+	reopened.Close()
+}
+
+func TestClosedStore_WritesFail(t *testing.T) {
+	store, err := NewStoreFromWAL(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	users, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	users.Put(userDoc("1", "Alice"))
+	if err := store.Close(); err != nil {
+		t.Fatalf("unexpected error on Close: %v", err)
+	}
+
+	if _, err := users.Put(userDoc("2", "Bob")); err != ErrStoreClosed {
+		t.Fatalf("expected ErrStoreClosed on Put, got %v", err)
+	}
+	if err := users.Delete("1"); err != ErrStoreClosed {
+		t.Fatalf("expected ErrStoreClosed on Delete, got %v", err)
+	}
+	if _, err := store.CreateCollection("posts", &CollectionConfig{PrimaryKey: "id"}); err != ErrStoreClosed {
+		t.Fatalf("expected ErrStoreClosed on CreateCollection, got %v", err)
+	}
+	if users.Exists("2") || !users.Exists("1") {
+		t.Fatalf("expected failed writes not to be applied")
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("expected a second Close to succeed, got %v", err)
+	}
+
+	// A store without a log accepts writes as before.
+	mem, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	if _, err := mem.Put(userDoc("1", "Alice")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+}
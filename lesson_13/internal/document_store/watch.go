@@ -0,0 +1,159 @@
+package document_store
+
+import (
+	"errors"
+)
+
+var ErrSubscriberTooSlow = errors.New("subscriber fell behind")
+
+const defaultWatchBuffer = 64
+
+type ChangeType string
+
+const (
+	ChangeInsert ChangeType = "insert"
+	ChangeUpdate ChangeType = "update"
+	ChangeDelete ChangeType = "delete"
+)
+
+// ChangeEvent describes a committed write. OldDoc is nil for an insert and
+// NewDoc is nil for a delete.
+type ChangeEvent struct {
+	Type       ChangeType
+	Collection string
+	Key        string
+	OldDoc     *Document
+	NewDoc     *Document
+}
+
+// WatchOptions select the events of a subscription: those for Key when it is
+// set, and those whose old or new document matches Filter when it is set.
+// Buffer is the number of events that may wait for the subscriber.
+type WatchOptions struct {
+	Key    string
+	Filter *Filter
+	Buffer int
+}
+
+// Subscription delivers change events in commit order. Writers never wait
+// for a subscriber: one that lets Buffer events pile up is closed, and Err
+// then returns ErrSubscriberTooSlow.
+type Subscription struct {
+	collection *CollectionImpl
+	options    WatchOptions
+	events     chan ChangeEvent
+	closed     bool
+	err        error
+}
+
+// Watch subscribes to the changes of the collection made after it returns.
+func (c *CollectionImpl) Watch(options WatchOptions) (*Subscription, error) {
+	if options.Filter != nil {
+		if err := options.Filter.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if options.Buffer <= 0 {
+		options.Buffer = defaultWatchBuffer
+	}
+	sub := &Subscription{
+		collection: c,
+		options:    options,
+		events:     make(chan ChangeEvent, options.Buffer),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dropped {
+		return nil, ErrCollectionNotFound
+	}
+	if c.subscribers == nil {
+		c.subscribers = make(map[*Subscription]struct{})
+	}
+	c.subscribers[sub] = struct{}{}
+	return sub, nil
+}
+
+// Events is closed when the subscription ends.
+func (s *Subscription) Events() <-chan ChangeEvent {
+	return s.events
+}
+
+// Err returns why the subscription ended on its own: ErrSubscriberTooSlow,
+// or ErrCollectionNotFound when the collection is deleted.
+func (s *Subscription) Err() error {
+	s.collection.mu.RLock()
+	defer s.collection.mu.RUnlock()
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.collection.mu.Lock()
+	defer s.collection.mu.Unlock()
+	s.end(nil)
+}
+
+// end must be called with the collection write lock held.
+func (s *Subscription) end(err error) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.err = err
+	close(s.events)
+	delete(s.collection.subscribers, s)
+}
+
+func (s *Subscription) wants(event *ChangeEvent) bool {
+	if s.options.Key != "" && s.options.Key != event.Key {
+		return false
+	}
+	if f := s.options.Filter; f != nil {
+		return event.OldDoc != nil && f.matches(event.OldDoc) || event.NewDoc != nil && f.matches(event.NewDoc)
+	}
+	return true
+}
+
+// notify sends the event for a committed write to the subscribers. Must be
+// called with the write lock held.
+func (c *CollectionImpl) notify(key string, oldDoc, newDoc *Document) {
+	if len(c.subscribers) == 0 {
+		return
+	}
+	event := ChangeEvent{Collection: c.name, Key: key}
+	switch {
+	case oldDoc == nil:
+		event.Type = ChangeInsert
+	case newDoc == nil:
+		event.Type = ChangeDelete
+	default:
+		event.Type = ChangeUpdate
+	}
+	// The stored documents are only read to match subscribers; each one
+	// gets its own copies.
+	event.OldDoc, event.NewDoc = oldDoc, newDoc
+	for sub := range c.subscribers {
+		if !sub.wants(&event) {
+			continue
+		}
+		delivered := event
+		if oldDoc != nil {
+			delivered.OldDoc = cloneDocument(oldDoc)
+		}
+		if newDoc != nil {
+			delivered.NewDoc = cloneDocument(newDoc)
+		}
+		select {
+		case sub.events <- delivered:
+		default:
+			sub.end(ErrSubscriberTooSlow)
+		}
+	}
+}
+
+// endSubscriptions must be called with the write lock held.
+func (c *CollectionImpl) endSubscriptions(err error) {
+	for sub := range c.subscribers {
+		sub.end(err)
+	}
+}
@@ -0,0 +1,183 @@
+package document_store
+
+import (
+	"testing"
+)
+
+func nextEvent(t *testing.T, sub *Subscription) ChangeEvent {
+	t.Helper()
+	select {
+	case event, ok := <-sub.Events():
+		if !ok {
+			t.Fatalf("expected an event, subscription ended with %v", sub.Err())
+		}
+		return event
+	default:
+		t.Fatalf("expected an event, got none")
+	}
+	return ChangeEvent{}
+}
+
+func assertNoEvent(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case event := <-sub.Events():
+		t.Fatalf("expected no event, got %+v", event)
+	default:
+	}
+}
+
+func docName(doc *Document) any {
+	if doc == nil {
+		return nil
+	}
+	return doc.Fields["name"].Value
+}
+
+func TestWatch_InsertUpdateDelete(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(userDoc("0", "before"))
+	sub, err := col.Watch(WatchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error on Watch: %v", err)
+	}
+	defer sub.Close()
+
+	col.Put(userDoc("1", "Alice"))
+	col.Update("1", Patch{Set: map[string]DocumentField{"name": *str("Alicia")}})
+	col.Delete("1")
+	col.Delete("missing")
+
+	want := []struct {
+		typ      ChangeType
+		old, new any
+	}{
+		{ChangeInsert, nil, "Alice"},
+		{ChangeUpdate, "Alice", "Alicia"},
+		{ChangeDelete, "Alicia", nil},
+	}
+	for _, w := range want {
+		event := nextEvent(t, sub)
+		if event.Type != w.typ || event.Key != "1" || event.Collection != "users" ||
+			docName(event.OldDoc) != w.old || docName(event.NewDoc) != w.new {
+			t.Fatalf("expected %s %v -> %v, got %+v", w.typ, w.old, w.new, event)
+		}
+	}
+	assertNoEvent(t, sub)
+}
+
+func TestWatch_KeyAndFilter(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	byKey, _ := col.Watch(WatchOptions{Key: "2"})
+	byName, _ := col.Watch(WatchOptions{Filter: &Filter{Op: FilterOpEq, Field: "name", Value: str("Bob")}})
+
+	col.Put(userDoc("1", "Alice"))
+	col.Put(userDoc("2", "Carol"))
+	col.Put(userDoc("1", "Bob"))
+	col.Put(userDoc("1", "Dave"))
+
+	if event := nextEvent(t, byKey); event.Key != "2" {
+		t.Fatalf("expected event for key 2, got %+v", event)
+	}
+	assertNoEvent(t, byKey)
+
+	// Documents entering and leaving the filter both match.
+	if event := nextEvent(t, byName); docName(event.NewDoc) != "Bob" {
+		t.Fatalf("expected Bob to enter, got %+v", event)
+	}
+	if event := nextEvent(t, byName); docName(event.OldDoc) != "Bob" || docName(event.NewDoc) != "Dave" {
+		t.Fatalf("expected Bob to leave, got %+v", event)
+	}
+	assertNoEvent(t, byName)
+
+	if _, err := col.Watch(WatchOptions{Filter: &Filter{Op: "like"}}); err != ErrInvalidFilter {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+}
+
+func TestWatch_TransactionsAndBatches(t *testing.T) {
+	store, pending, processed := newQueueStore(t, "")
+	pendingSub, _ := pending.Watch(WatchOptions{})
+	processedSub, _ := processed.Watch(WatchOptions{})
+
+	failed := store.Begin()
+	failed.Put("processed", userDoc("1", "job1"))
+	failed.Delete("pending", "missing")
+	failed.Commit()
+	assertNoEvent(t, processedSub)
+
+	tx := store.Begin()
+	tx.Delete("pending", "1")
+	tx.Put("processed", userDoc("1", "job1"))
+	tx.Commit()
+	if event := nextEvent(t, pendingSub); event.Type != ChangeDelete || event.Key != "1" {
+		t.Fatalf("expected delete of 1 from pending, got %+v", event)
+	}
+	if event := nextEvent(t, processedSub); event.Type != ChangeInsert || event.Key != "1" {
+		t.Fatalf("expected insert of 1 into processed, got %+v", event)
+	}
+
+	processed.PutMany([]*Document{userDoc("2", "job2"), nil, userDoc("1", "job1b")})
+	if event := nextEvent(t, processedSub); event.Type != ChangeInsert || event.Key != "2" {
+		t.Fatalf("expected insert of 2, got %+v", event)
+	}
+	if event := nextEvent(t, processedSub); event.Type != ChangeUpdate || docName(event.OldDoc) != "job1" {
+		t.Fatalf("expected update of 1, got %+v", event)
+	}
+	assertNoEvent(t, processedSub)
+}
+
+func TestWatch_SlowSubscriberIsClosed(t *testing.T) {
+	col, _ := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	slow, _ := col.Watch(WatchOptions{Buffer: 2})
+	fast, _ := col.Watch(WatchOptions{Buffer: 2})
+
+	for _, id := range []string{"1", "2", "3"} {
+		col.Put(userDoc(id, id))
+		if id != "3" {
+			nextEvent(t, fast)
+		}
+	}
+	nextEvent(t, fast)
+
+	nextEvent(t, slow)
+	nextEvent(t, slow)
+	if _, ok := <-slow.Events(); ok {
+		t.Fatalf("expected the slow subscription to be closed")
+	}
+	if err := slow.Err(); err != ErrSubscriberTooSlow {
+		t.Fatalf("expected ErrSubscriberTooSlow, got %v", err)
+	}
+	if fast.Err() != nil {
+		t.Fatalf("expected the fast subscription to stay open, got %v", fast.Err())
+	}
+}
+
+func TestWatch_CloseAndDeleteCollection(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	closed, _ := col.Watch(WatchOptions{})
+	dropped, _ := col.Watch(WatchOptions{})
+
+	closed.Close()
+	closed.Close()
+	col.Put(userDoc("1", "Alice"))
+	if _, ok := <-closed.Events(); ok {
+		t.Fatalf("expected no events after Close")
+	}
+	if closed.Err() != nil {
+		t.Fatalf("expected no error after Close, got %v", closed.Err())
+	}
+
+	nextEvent(t, dropped)
+	store.DeleteCollection("users")
+	if _, ok := <-dropped.Events(); ok {
+		t.Fatalf("expected the subscription to end with the collection")
+	}
+	if err := dropped.Err(); err != ErrCollectionNotFound {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+	if _, err := col.Watch(WatchOptions{}); err != ErrCollectionNotFound {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+}
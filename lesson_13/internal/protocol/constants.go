@@ -12,4 +12,32 @@ const (
 	CmdCreateIndex      = "CreateIndex"
 	CmdDeleteIndex      = "DeleteIndex"
 	CmdQuery            = "Query"
+	CmdFind             = "Find"
+	CmdAggregate        = "Aggregate"
+	CmdCount            = "Count"
+	CmdExists           = "Exists"
+	CmdUpdate           = "Update"
+	CmdBegin            = "Begin"
+	CmdCommit           = "Commit"
+	CmdRollback         = "Rollback"
+	CmdBatch            = "Batch"
+	CmdWatch            = "Watch"
+)
+
+// Operations of a Batch request.
+const (
+	BatchOpPut    = "put"
+	BatchOpDelete = "delete"
+)
+
+// Error codes returned in Response.Code.
+const (
+	CodeBadRequest        = "bad_request"
+	CodeNotFound          = "not_found"
+	CodeAlreadyExists     = "already_exists"
+	CodeUniqueViolation   = "unique_violation"
+	CodeVersionConflict   = "version_conflict"
+	CodeSubscriberTooSlow = "subscriber_too_slow"
+	CodeSchemaViolation   = "schema_violation"
+	CodeInternal          = "internal"
 )
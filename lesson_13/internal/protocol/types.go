@@ -1,18 +1,96 @@
 package protocol
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type DocFieldWire struct {
 	Type  string `json:"type"`
 	Value any    `json:"value"`
 }
 
 type DocWire struct {
-	Fields map[string]DocFieldWire `json:"fields"`
+	Fields    map[string]DocFieldWire `json:"fields"`
+	Version   uint64                  `json:"version,omitempty"`
+	ExpiresAt *time.Time              `json:"expires_at,omitempty"`
 }
 
 type QueryParamsWire struct {
-	Desc     bool    `json:"desc"`
-	MinValue *string `json:"min_value,omitempty"`
-	MaxValue *string `json:"max_value,omitempty"`
+	Desc     bool           `json:"desc"`
+	Prefix   []DocFieldWire `json:"prefix,omitempty"`
+	MinValue *DocFieldWire  `json:"min_value,omitempty"`
+	MaxValue *DocFieldWire  `json:"max_value,omitempty"`
+}
+
+type FilterWire struct {
+	Op      string         `json:"op"`
+	Field   string         `json:"field,omitempty"`
+	Value   *DocFieldWire  `json:"value,omitempty"`
+	Values  []DocFieldWire `json:"values,omitempty"`
+	Filters []FilterWire   `json:"filters,omitempty"`
+}
+
+type SortWire struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
+}
+
+type IndexWire struct {
+	Fields   []string `json:"fields,omitempty"`
+	Unique   bool     `json:"unique,omitempty"`
+	Multikey bool     `json:"multikey,omitempty"`
+}
+
+type AccumulatorWire struct {
+	Name  string `json:"name"`
+	Op    string `json:"op"`
+	Field string `json:"field,omitempty"`
+}
+
+type PatchWire struct {
+	Set   map[string]DocFieldWire `json:"$set,omitempty"`
+	Unset []string                `json:"$unset,omitempty"`
+	Inc   map[string]float64      `json:"$inc,omitempty"`
+	Push  map[string]DocFieldWire `json:"$push,omitempty"`
+	Pull  map[string]DocFieldWire `json:"$pull,omitempty"`
+}
+
+type BatchOpWire struct {
+	Op  string `json:"op"`
+	Key string `json:"key,omitempty"`
+	// KeyParts replaces Key like Request.KeyParts.
+	KeyParts []any    `json:"key_parts,omitempty"`
+	Doc      *DocWire `json:"doc,omitempty"`
+}
+
+type ResultWire struct {
+	OK      bool             `json:"ok"`
+	Err     string           `json:"err,omitempty"`
+	Code    string           `json:"code,omitempty"`
+	Errors  []FieldErrorWire `json:"errors,omitempty"`
+	Key     string           `json:"key,omitempty"`
+	Version uint64           `json:"version,omitempty"`
+}
+
+// FieldErrorWire explains why the field at a path such as "address.city" was
+// rejected.
+type FieldErrorWire struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type EventWire struct {
+	Type       string   `json:"type"`
+	Collection string   `json:"collection"`
+	Key        string   `json:"key"`
+	Old        *DocWire `json:"old,omitempty"`
+	New        *DocWire `json:"new,omitempty"`
+}
+
+type ProjectionWire struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
 }
 
 type Request struct {
@@ -20,20 +98,59 @@ type Request struct {
 
 	Name   string `json:"name,omitempty"`
 	Config *struct {
-		PrimaryKey string `json:"primary_key"`
+		PrimaryKey string   `json:"primary_key"`
+		NumericKey bool     `json:"numeric_key,omitempty"`
+		KeyFields  []string `json:"key_fields,omitempty"`
+		// TTL is a duration such as "30m".
+		TTL          string `json:"ttl,omitempty"`
+		TTLField     string `json:"ttl_field,omitempty"`
+		MaxDocuments int    `json:"max_documents,omitempty"`
+		MaxBytes     int    `json:"max_bytes,omitempty"`
+		GenerateKeys bool   `json:"generate_keys,omitempty"`
+		// Schema is a JSON Schema for the documents.
+		Schema json.RawMessage `json:"schema,omitempty"`
 	} `json:"config,omitempty"`
 
-	Collection string           `json:"collection,omitempty"`
-	Key        string           `json:"key,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	Key        string `json:"key,omitempty"`
+	// KeyParts is the primary key as values, such as ["acme", 7] for a
+	// composite key, instead of Key in its canonical form.
+	KeyParts   []any            `json:"key_parts,omitempty"`
+	Version    *uint64          `json:"version,omitempty"`
 	Doc        *DocWire         `json:"doc,omitempty"`
 	FieldName  string           `json:"field_name,omitempty"`
+	Index      *IndexWire       `json:"index,omitempty"`
 	Params     *QueryParamsWire `json:"params,omitempty"`
+	Filter     *FilterWire      `json:"filter,omitempty"`
+	Projection *ProjectionWire  `json:"projection,omitempty"`
+	Patch      *PatchWire       `json:"patch,omitempty"`
+	Ops        []BatchOpWire    `json:"ops,omitempty"`
+
+	Limit  int        `json:"limit,omitempty"`
+	Skip   int        `json:"skip,omitempty"`
+	Cursor string     `json:"cursor,omitempty"`
+	Sort   []SortWire `json:"sort,omitempty"`
+
+	GroupBy      string            `json:"group_by,omitempty"`
+	Accumulators []AccumulatorWire `json:"accumulators,omitempty"`
 }
 
 type Response struct {
-	OK    bool       `json:"ok"`
-	Err   string     `json:"err,omitempty"`
-	Doc   *DocWire   `json:"doc,omitempty"`
-	Docs  []DocWire  `json:"docs,omitempty"`
-	Names []string   `json:"names,omitempty"`
+	OK     bool             `json:"ok"`
+	Err    string           `json:"err,omitempty"`
+	Code   string           `json:"code,omitempty"`
+	Errors []FieldErrorWire `json:"errors,omitempty"`
+	Doc    *DocWire         `json:"doc,omitempty"`
+	Docs   []DocWire        `json:"docs,omitempty"`
+	Names  []string         `json:"names,omitempty"`
+
+	// Key is the key a Put stored the document under.
+	Key        string `json:"key,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Count      *int   `json:"count,omitempty"`
+	Exists     *bool  `json:"exists,omitempty"`
+	Version    uint64 `json:"version,omitempty"`
+
+	Results []ResultWire `json:"results,omitempty"`
+	Event   *EventWire   `json:"event,omitempty"`
 }